@@ -139,8 +139,10 @@ func AlfredInfo(title string, sub *string) *AlfredResponseItem {
 	}
 }
 
-func BackgroundUpdate() error {
-	cmd := exec.Command(os.Args[0], "update")
+// BackgroundUpdate runs the binary again in a detached process with args,
+// which should include the update command and any global options to carry over
+func BackgroundUpdate(args ...string) error {
+	cmd := exec.Command(os.Args[0], args...)
 	if err := cmd.Start(); err != nil {
 		return err
 	}
@@ -34,6 +34,7 @@ func Reverse[S ~[]E, E any](s S) {
 
 type Timespan time.Duration
 
+// Format describes the duration in the current language, e.g. 2hours 5min or 2 jam 5 minit
 func (ts Timespan) Format() string {
 	res := ""
 	d := time.Duration(ts)
@@ -41,12 +42,12 @@ func (ts Timespan) Format() string {
 		v := d / unit
 		if v > 0 {
 			d -= v * unit
-			res += fmt.Sprintf("%d%s ", v, Or(v == 1, single, plural))
+			res += T(Or(v == 1, single, plural), int64(v)) + " "
 		}
 	}
-	calc(time.Hour, "hour", "hours")
-	calc(time.Minute, "min", "min")
-	calc(time.Second, "sec", "sec")
+	calc(time.Hour, "%dhour", "%dhours")
+	calc(time.Minute, "%dmin", "%dmin")
+	calc(time.Second, "%dsec", "%dsec")
 
 	return strings.Trim(res, " ")
 }
@@ -57,3 +58,7 @@ func Or[X any](cond bool, ok X, ko X) X {
 	}
 	return ko
 }
+
+func Ptr[X any](v X) *X {
+	return &v
+}
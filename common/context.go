@@ -9,10 +9,26 @@ const (
 	ENV_PREFIX = "WS_"
 )
 
+// Modes lists the output modes
+var Modes = []string{"cli", "alfred", "json", "waybar", "polybar", "i3blocks", "tmux"}
+
 type Config struct {
-	IsDebug bool
-	Mode    string
-	DbPath  string
+	IsDebug  bool
+	Mode     string
+	DbPath   string
+	Provider string
+	// TimeFormat is 12h, 24h or a Go time layout, empty to use the stored setting
+	TimeFormat string
+	// Timezone is the IANA name times are converted to for display, empty for Malaysian time
+	Timezone string
+	// Language is the code of the message language, empty to detect it from the locale
+	Language string
+	// Format is a text/template to print prayer times with, empty to use the output mode
+	Format string
+	// ConfigPath is the TOML config file
+	ConfigPath string
+	// Profile is the config file profile in use, empty for the top level settings only
+	Profile string
 }
 type Ctx struct {
 	Config *Config
@@ -31,3 +47,16 @@ func (c *Ctx) LoadEnv() {
 func (c *Config) IsAlfred() bool {
 	return c.Mode == "alfred"
 }
+
+func (c *Config) IsJSON() bool {
+	return c.Mode == "json"
+}
+
+// IsStatusBar reports whether the output is a one line next prayer for a status bar
+func (c *Config) IsStatusBar() bool {
+	switch c.Mode {
+	case "waybar", "polybar", "i3blocks", "tmux":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,257 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	LangEnglish = "en"
+	LangMalay   = "ms"
+	LangArabic  = "ar"
+)
+
+// catalogue translates messages, keyed by the English text used in the code.
+// English only lists the prayer and Hijri month names, which are keyed by the JAKIM (Malay) names.
+var catalogue = map[string]map[string]string{
+	LangEnglish: {
+		"Subuh":  "Fajr",
+		"Syuruk": "Sunrise",
+		"Zohor":  "Dhuhr",
+		"Asar":   "Asr",
+		"Isyak":  "Isha",
+
+		"Rabiulawal":   "Rabi al-Awwal",
+		"Rabiulakhir":  "Rabi al-Thani",
+		"Jamadilawal":  "Jumada al-Ula",
+		"Jamadilakhir": "Jumada al-Akhirah",
+		"Rejab":        "Rajab",
+		"Syaaban":      "Shaban",
+		"Syawal":       "Shawwal",
+		"Zulkaedah":    "Dhu al-Qadah",
+		"Zulhijjah":    "Dhu al-Hijjah",
+	},
+	LangMalay: {
+		"Date":             "Tarikh",
+		"Hijri":            "Hijrah",
+		"Locations":        "Lokasi",
+		"Timezone":         "Zon waktu",
+		"State":            "Negeri",
+		"Nearest":          "Terdekat",
+		"Current":          "Sekarang",
+		"Today":            "Hari ini",
+		"In %s":            "Dalam %s",
+		"%s in %s":         "%s dalam %s",
+		"%s %s ago":        "%s %s yang lalu",
+		"Adjusted %s":      "Dilaraskan %s",
+		"%+d min":          "%+d minit",
+		"%dhour":           "%d jam",
+		"%dhours":          "%d jam",
+		"%dmin":            "%d minit",
+		"%dsec":            "%d saat",
+		"Change Zone | %s": "Tukar Zon | %s",
+		"near %s":          "berhampiran %s",
+		"Zone not found":   "Zon tidak dijumpai",
+		"Current prayer":   "Solat semasa",
+		"Next prayer":      "Solat seterusnya",
+		"Next prayer time": "Waktu solat seterusnya",
+		"calculated":       "dikira",
+		"Calculated times, they may differ from JAKIM's by a minute or two": "Waktu dikira, mungkin berbeza seminit dua daripada waktu JAKIM",
+		"Fasting":          "Tempoh puasa",
+		"Iftar in %s":      "Berbuka dalam %s",
+		"Sahur ends in %s": "Sahur berakhir dalam %s",
+		"Start and end dates are calculated, they may differ from JAKIM's announcement": "Tarikh mula dan akhir adalah kiraan, mungkin berbeza daripada pengumuman JAKIM",
+		"adjusted from the official JAKIM time: %s":                                     "dilaraskan daripada waktu rasmi JAKIM: %s",
+		"Search zone (empty to cancel): ":                                               "Cari zon (kosong untuk batal): ",
+		"No zone matches [%s]":                                                          "Tiada zon sepadan dengan [%s]",
+		"Select [1-%d], search again or empty to cancel: ":                              "Pilih [1-%d], cari semula atau kosong untuk batal: ",
+
+		"Zones":                                "Zon",
+		"added %s - %s":                        "ditambah %s - %s",
+		"renamed %s - %s":                      "dinamakan semula %s - %s",
+		"removed %s - %s":                      "dibuang %s - %s",
+		"up to date":                           "terkini",
+		"failed, %s":                           "gagal, %s",
+		"not available, retried recently":      "tiada, baru dicuba semula",
+		"up to date (%d days)":                 "terkini (%d hari)",
+		"not published yet":                    "belum diterbitkan",
+		"updated (%d days)":                    "dikemas kini (%d hari)",
+		"unchanged (%d days)":                  "tiada perubahan (%d hari)",
+		"%d update(s) failed":                  "%d kemas kini gagal",
+		"at prayer time":                       "pada waktu solat",
+		"%d min before":                        "%d minit sebelum",
+		"%d min after":                         "%d minit selepas",
+		"timeout %s":                           "had masa %s",
+		"method not allowed":                   "kaedah tidak dibenarkan",
+		"not found":                            "tidak dijumpai",
+		"zone list is not available":           "senarai zon tiada",
+		"zone with id [%s] not found":          "zon dengan id [%s] tidak dijumpai",
+		"invalid mode [%s]":                    "mod tidak sah [%s]",
+		"to date must not be before from date": "tarikh akhir tidak boleh sebelum tarikh mula",
+		"no prayer times found for zone [%s]":  "tiada waktu solat dijumpai untuk zon [%s]",
+	},
+	LangArabic: {
+		"Imsak":   "الإمساك",
+		"Subuh":   "الفجر",
+		"Syuruk":  "الشروق",
+		"Zohor":   "الظهر",
+		"Asar":    "العصر",
+		"Maghrib": "المغرب",
+		"Isyak":   "العشاء",
+
+		"Muharram":     "محرم",
+		"Safar":        "صفر",
+		"Rabiulawal":   "ربيع الأول",
+		"Rabiulakhir":  "ربيع الآخر",
+		"Jamadilawal":  "جمادى الأولى",
+		"Jamadilakhir": "جمادى الآخرة",
+		"Rejab":        "رجب",
+		"Syaaban":      "شعبان",
+		"Ramadan":      "رمضان",
+		"Syawal":       "شوال",
+		"Zulkaedah":    "ذو القعدة",
+		"Zulhijjah":    "ذو الحجة",
+
+		"Date":             "التاريخ",
+		"Hijri":            "الهجري",
+		"Locations":        "المواقع",
+		"Timezone":         "المنطقة الزمنية",
+		"State":            "الولاية",
+		"Nearest":          "الأقرب",
+		"Current":          "الحالية",
+		"Today":            "اليوم",
+		"In %s":            "بعد %s",
+		"%s in %s":         "%s بعد %s",
+		"%s %s ago":        "%s منذ %s",
+		"Adjusted %s":      "معدّل %s",
+		"%+d min":          "%+d دقيقة",
+		"%dhour":           "%d ساعة",
+		"%dhours":          "%d ساعات",
+		"%dmin":            "%d دقيقة",
+		"%dsec":            "%d ثانية",
+		"Change Zone | %s": "تغيير المنطقة | %s",
+		"near %s":          "قرب %s",
+		"Zone not found":   "لم يتم العثور على المنطقة",
+		"Current prayer":   "الصلاة الحالية",
+		"Next prayer":      "الصلاة التالية",
+		"Next prayer time": "وقت الصلاة التالية",
+		"calculated":       "محسوب",
+		"Calculated times, they may differ from JAKIM's by a minute or two": "أوقات محسوبة، قد تختلف عن أوقات جاكيم بدقيقة أو دقيقتين",
+		"Fasting":          "مدة الصيام",
+		"Iftar in %s":      "الإفطار بعد %s",
+		"Sahur ends in %s": "ينتهي السحور بعد %s",
+		"Start and end dates are calculated, they may differ from JAKIM's announcement": "تواريخ البداية والنهاية محسوبة وقد تختلف عن إعلان جاكيم",
+		"adjusted from the official JAKIM time: %s":                                     "معدّل عن التوقيت الرسمي لجاكيم: %s",
+		"Search zone (empty to cancel): ":                                               "ابحث عن منطقة (اتركه فارغاً للإلغاء): ",
+		"No zone matches [%s]":                                                          "لا توجد منطقة تطابق [%s]",
+		"Select [1-%d], search again or empty to cancel: ":                              "اختر [1-%d]، أو ابحث مجدداً، أو اتركه فارغاً للإلغاء: ",
+
+		"Zones":                                "المناطق",
+		"added %s - %s":                        "أضيفت %s - %s",
+		"renamed %s - %s":                      "أعيدت تسميتها %s - %s",
+		"removed %s - %s":                      "أزيلت %s - %s",
+		"up to date":                           "محدّثة",
+		"failed, %s":                           "فشل، %s",
+		"not available, retried recently":      "غير متاحة، أعيدت المحاولة مؤخراً",
+		"up to date (%d days)":                 "محدّثة (%d يوم)",
+		"not published yet":                    "لم تُنشر بعد",
+		"updated (%d days)":                    "تم التحديث (%d يوم)",
+		"unchanged (%d days)":                  "دون تغيير (%d يوم)",
+		"%d update(s) failed":                  "فشل %d تحديث",
+		"at prayer time":                       "عند وقت الصلاة",
+		"%d min before":                        "قبل %d دقيقة",
+		"%d min after":                         "بعد %d دقيقة",
+		"timeout %s":                           "المهلة %s",
+		"method not allowed":                   "الطريقة غير مسموح بها",
+		"not found":                            "غير موجود",
+		"zone list is not available":           "قائمة المناطق غير متاحة",
+		"zone with id [%s] not found":          "لم يتم العثور على المنطقة [%s]",
+		"invalid mode [%s]":                    "وضع غير صالح [%s]",
+		"to date must not be before from date": "يجب ألا يسبق تاريخ النهاية تاريخ البداية",
+		"no prayer times found for zone [%s]":  "لم يتم العثور على أوقات الصلاة للمنطقة [%s]",
+	},
+}
+
+var language = LangMalay
+
+// Languages lists the supported language codes
+func Languages() []string {
+	var res []string
+	for lang := range catalogue {
+		res = append(res, lang)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Language returns the language messages are translated to
+func Language() string {
+	return language
+}
+
+// ParseLanguage returns the code of a supported language, lang is a code such as ms or a locale
+// such as ms_MY.UTF-8
+func ParseLanguage(lang string) (string, error) {
+	code := localeLanguage(lang)
+	if _, ok := catalogue[code]; !ok {
+		return "", fmt.Errorf("language [%s] not supported, expected one of %s", lang, strings.Join(Languages(), "|"))
+	}
+	return code, nil
+}
+
+// SetLanguage selects the language of T, see ParseLanguage
+func SetLanguage(lang string) error {
+	code, err := ParseLanguage(lang)
+	if err != nil {
+		return err
+	}
+	language = code
+	return nil
+}
+
+// DetectLanguage returns the supported language of the environment's locale, Malay otherwise, as
+// JAKIM names the prayers
+func DetectLanguage() string {
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(key); len(value) != 0 {
+			if code := localeLanguage(value); len(catalogue[code]) != 0 {
+				return code
+			}
+			return LangMalay
+		}
+	}
+	return LangMalay
+}
+
+func localeLanguage(locale string) string {
+	code := strings.ToLower(locale)
+	if i := strings.IndexAny(code, "_-.@"); i >= 0 {
+		code = code[:i]
+	}
+	return code
+}
+
+// Translations returns msg and its translation in every language
+func Translations(msg string) []string {
+	res := []string{msg}
+	for _, lang := range Languages() {
+		if translated, ok := catalogue[lang][msg]; ok {
+			res = append(res, translated)
+		}
+	}
+	return res
+}
+
+// T translates msg to the current language and formats it with args, msg is returned as is when
+// there is no translation
+func T(msg string, args ...any) string {
+	if translated, ok := catalogue[language][msg]; ok {
+		msg = translated
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/fatih/color"
@@ -9,9 +11,15 @@ import (
 	"github.com/urfave/cli/v2"
 	"io"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
+	"unicode/utf8"
 )
 
 func main() {
@@ -20,6 +28,7 @@ func main() {
 	cfg := ctx.Config
 	dir, _ := os.UserCacheDir()
 	defaultDbPath := filepath.Join(dir, fmt.Sprintf("%s.db", filepath.Base(os.Args[0])))
+	defaultConfigPath := services.DefaultConfigPath(filepath.Base(os.Args[0]))
 	app := &cli.App{
 		UseShortOptionHandling: true,
 		DefaultCommand:         "get",
@@ -36,8 +45,7 @@ func main() {
 			&cli.StringFlag{
 				Name:        "output",
 				Aliases:     []string{},
-				Value:       "cli",
-				Usage:       "output mode [cli, alfred]",
+				Usage:       "output mode [" + strings.Join(common.Modes, ", ") + "] (default: from config, or \"cli\")",
 				EnvVars:     []string{common.ENV_PREFIX + "MODE"},
 				Destination: &cfg.Mode,
 			},
@@ -48,11 +56,96 @@ func main() {
 				Usage:       "path to `DB_FILE`",
 				Destination: &cfg.DbPath,
 			},
+			&cli.StringFlag{
+				Name:        "config",
+				Aliases:     []string{},
+				Value:       defaultConfigPath,
+				Usage:       "path to the TOML `CONFIG_FILE`",
+				EnvVars:     []string{common.ENV_PREFIX + "CONFIG"},
+				Destination: &cfg.ConfigPath,
+			},
+			&cli.StringFlag{
+				Name:        "profile",
+				Aliases:     []string{},
+				Usage:       "use the settings of a config file `PROFILE`, e.g. home or office, over the top level ones",
+				EnvVars:     []string{common.ENV_PREFIX + "PROFILE"},
+				Destination: &cfg.Profile,
+			},
+			&cli.StringFlag{
+				Name:        "provider",
+				Aliases:     []string{},
+				Usage:       "prayer time `PROVIDER` [" + strings.Join(services.ProviderNames(), ", ") + "] (default: from config, or \"" + services.DefaultProvider + "\")",
+				EnvVars:     []string{common.ENV_PREFIX + "PROVIDER"},
+				Destination: &cfg.Provider,
+			},
+			&cli.StringFlag{
+				Name:        "time-format",
+				Aliases:     []string{},
+				Usage:       "time display `FORMAT` [12h, 24h] or a Go time layout (default: from config, or \"12h\")",
+				EnvVars:     []string{common.ENV_PREFIX + "TIME_FORMAT"},
+				Destination: &cfg.TimeFormat,
+			},
+			&cli.StringFlag{
+				Name:        "tz",
+				Aliases:     []string{},
+				Usage:       "show times converted to `TIMEZONE`, an IANA name or local (default: from config, or Asia/Kuala_Lumpur)",
+				EnvVars:     []string{common.ENV_PREFIX + "TZ"},
+				Destination: &cfg.Timezone,
+			},
+			&cli.StringFlag{
+				Name:        "lang",
+				Aliases:     []string{},
+				Usage:       "`LANGUAGE` of prayer names and messages [" + strings.Join(common.Languages(), ", ") + "] (default: from config, then the locale, or \"ms\")",
+				EnvVars:     []string{common.ENV_PREFIX + "LANG"},
+				Destination: &cfg.Language,
+			},
+			&cli.StringFlag{
+				Name:        "format",
+				Aliases:     []string{},
+				Usage:       "print get and next with a Go text/`TEMPLATE`, e.g. '{{.Next}} {{duration .Remaining}}' (default: from config)",
+				EnvVars:     []string{common.ENV_PREFIX + "FORMAT"},
+				Destination: &cfg.Format,
+			},
 		},
 		Before: func(context *cli.Context) error {
+			if err := services.LoadConfig(ctx); err != nil {
+				return err
+			}
+			if !context.IsSet("output") {
+				cfg.Mode = services.GetConfig(ctx, services.ConfigOutput)
+			}
+			if len(cfg.Timezone) == 0 {
+				cfg.Timezone = services.GetConfig(ctx, services.ConfigTimezone)
+			}
+			if len(cfg.Language) == 0 {
+				cfg.Language = services.GetConfig(ctx, services.ConfigLanguage)
+			}
 			if cfg.IsAlfred() && !cfg.IsDebug {
 				log.SetOutput(io.Discard)
 			}
+			if len(cfg.Provider) != 0 {
+				if _, err := services.GetProvider(ctx); err != nil {
+					return err
+				}
+			}
+			if len(cfg.TimeFormat) != 0 {
+				if _, err := services.ParseTimeFormat(cfg.TimeFormat); err != nil {
+					return err
+				}
+			}
+			if len(cfg.Timezone) != 0 {
+				if _, err := services.ParseTimezone(cfg.Timezone); err != nil {
+					return err
+				}
+			}
+			if len(cfg.Format) != 0 {
+				if _, err := services.ParseTemplate(cfg.Format); err != nil {
+					return err
+				}
+			}
+			if err := common.SetLanguage(common.Or(len(cfg.Language) != 0, cfg.Language, common.DetectLanguage())); err != nil {
+				return err
+			}
 			return nil
 		},
 		Commands: []*cli.Command{
@@ -61,31 +154,385 @@ func main() {
 				Usage:  "Retrieve prayer time",
 				Action: handlePrayerTimes(ctx),
 
-				Flags: []cli.Flag{
+				Flags: append([]cli.Flag{
 					&cli.StringFlag{
 						Name:    "zone",
 						Aliases: []string{},
 						//Value:   "WLY01",
 						Usage: "Zone ID (received using `zone` command)",
 					},
-					&cli.StringFlag{
-						Name:    "mode",
-						Aliases: []string{},
-						Value:   "daily",
-						Usage:   "Result mode (daily|weekly|monthly|yearly)",
+					&cli.Float64Flag{
+						Name:  "lat",
+						Usage: "Use the zone at this latitude, requires --lon. Times are calculated offline outside every zone",
 					},
-				},
+					&cli.Float64Flag{
+						Name:  "lon",
+						Usage: "Use the zone at this longitude, requires --lat",
+					},
+				}, dateRangeFlags()...),
 			},
 			{
 				Name:   "zone",
 				Usage:  "List all accepted zone",
 				Action: handleZones(ctx),
+				Subcommands: []*cli.Command{
+					{
+						Name:      "search",
+						Usage:     "Search zones by location, state or id",
+						ArgsUsage: "<text>",
+						Action:    searchZones(ctx),
+						Flags: []cli.Flag{
+							&cli.IntFlag{
+								Name:  "limit",
+								Value: 10,
+								Usage: "Maximum number of results, 0 for all",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:   "locate",
+				Usage:  "Find the zone of a coordinate",
+				Action: handleLocate(ctx),
+				Flags: []cli.Flag{
+					&cli.Float64Flag{
+						Name:     "lat",
+						Usage:    "Latitude in decimal degrees",
+						Required: true,
+					},
+					&cli.Float64Flag{
+						Name:     "lon",
+						Usage:    "Longitude in decimal degrees",
+						Required: true,
+					},
+				},
+			},
+			{
+				Name:      "update",
+				Usage:     "Refresh cached zone list and prayer times",
+				Action:    handleUpdate(ctx),
+				ArgsUsage: "[zone-id...]",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "all",
+						Usage: "Refresh prayer times for every zone",
+					},
+					&cli.BoolFlag{
+						Name:    "force",
+						Aliases: []string{"f"},
+						Usage:   "Refresh even if the cached times are recent",
+					},
+				},
+			},
+			{
+				Name:  "export",
+				Usage: "Export prayer times to other formats",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "ics",
+						Usage:  "Export prayer times as an iCalendar (.ics) file",
+						Action: handleExportICS(ctx),
+						Flags: append([]cli.Flag{
+							&cli.StringFlag{
+								Name:  "zone",
+								Usage: "Zone ID (received using `zone` command)",
+							},
+							&cli.StringFlag{
+								Name:    "out",
+								Aliases: []string{"o"},
+								Usage:   "Write the calendar to `FILE` instead of stdout",
+							},
+							&cli.IntSliceFlag{
+								Name:  "alarm",
+								Usage: "Add a reminder `MINUTES` before each prayer, can be repeated",
+							},
+							&cli.DurationFlag{
+								Name:  "length",
+								Value: 15 * time.Minute,
+								Usage: "Length of each event",
+							},
+							&cli.StringSliceFlag{
+								Name:  "prayers",
+								Usage: "Only include these prayers, e.g. --prayers Subuh,Maghrib",
+							},
+						}, dateRangeFlags()...),
+					},
+				},
+			},
+			{
+				Name:    "watch",
+				Aliases: []string{"daemon"},
+				Usage:   "Stay resident and notify when prayer times arrive",
+				Description: "With --notify mqtt today's timetable and the current and next prayer are published as retained\n" +
+					"JSON to <topic>/<zone>/{today,current,next}, and each prayer as it starts to <topic>/<zone>/event.\n" +
+					"Home Assistant picks up the sensors through MQTT discovery unless --mqtt-discovery is empty.",
+				Action: handleWatch(ctx),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "zone",
+						Usage: "Zone ID to follow (default: the zone setting, re-read while running)",
+					},
+					&cli.IntSliceFlag{
+						Name:  "remind",
+						Usage: "Also notify `MINUTES` before each prayer, can be repeated",
+					},
+					&cli.StringSliceFlag{
+						Name:  "prayers",
+						Usage: "Only notify for these prayers, e.g. --prayers Subuh,Maghrib",
+					},
+					&cli.StringSliceFlag{
+						Name:  "notify",
+						Value: cli.NewStringSlice("stdout"),
+						Usage: "Where to send notifications [stdout, desktop, mqtt]",
+					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Log the hooks that would run instead of running them",
+					},
+					&cli.StringFlag{
+						Name:    "mqtt-broker",
+						Value:   services.DefaultMQTTBroker,
+						Usage:   "MQTT broker `URL` used by --notify mqtt",
+						EnvVars: []string{common.ENV_PREFIX + "MQTT_BROKER"},
+					},
+					&cli.StringFlag{
+						Name:    "mqtt-username",
+						Usage:   "MQTT broker username",
+						EnvVars: []string{common.ENV_PREFIX + "MQTT_USERNAME"},
+					},
+					&cli.StringFlag{
+						Name:    "mqtt-password",
+						Usage:   "MQTT broker password",
+						EnvVars: []string{common.ENV_PREFIX + "MQTT_PASSWORD"},
+					},
+					&cli.StringFlag{
+						Name:  "mqtt-client-id",
+						Usage: "MQTT client ID (default: assigned by the broker)",
+					},
+					&cli.StringFlag{
+						Name:  "mqtt-topic",
+						Value: services.DefaultMQTTTopic,
+						Usage: "Prefix of the MQTT topics, states go to <prefix>/<zone>/{today,current,next} and events to <prefix>/<zone>/event",
+					},
+					&cli.StringFlag{
+						Name:  "mqtt-discovery",
+						Value: services.DefaultMQTTDiscoveryPrefix,
+						Usage: "Home Assistant MQTT discovery prefix, empty to disable discovery",
+					},
+				},
+			},
+			{
+				Name:  "hook",
+				Usage: "Manage commands run by watch when a prayer time arrives",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "add",
+						Usage:     "Add a hook",
+						ArgsUsage: "<command>",
+						Action:    addHook(ctx),
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "prayer",
+								Value: services.AllPrayers,
+								Usage: "Prayer the hook runs for, e.g. Maghrib, or * for all",
+							},
+							&cli.IntFlag{
+								Name:  "before",
+								Usage: "Run `MINUTES` before the prayer time",
+							},
+							&cli.IntFlag{
+								Name:  "after",
+								Usage: "Run `MINUTES` after the prayer time",
+							},
+							&cli.DurationFlag{
+								Name:  "timeout",
+								Value: services.DefaultHookTimeout,
+								Usage: "Kill the command after this long",
+							},
+						},
+					},
+					{
+						Name:   "list",
+						Usage:  "List hooks",
+						Action: listHooks(ctx),
+					},
+					{
+						Name:      "remove",
+						Usage:     "Remove a hook",
+						ArgsUsage: "<hook-id>",
+						Action:    removeHook(ctx),
+					},
+					{
+						Name:      "run",
+						Usage:     "Run a hook now with the next prayer of the default zone, to test it",
+						ArgsUsage: "<hook-id>",
+						Action:    runHook(ctx),
+					},
+				},
+			},
+			{
+				Name:      "hijri",
+				Usage:     "Convert a date to Hijri, or a Hijri date such as \"1 Ramadan\" to a date",
+				ArgsUsage: "[date | hijri-date]",
+				Description: "Dates are YYYY-MM-DD, DD/MM/YYYY, today, tomorrow or yesterday. Hijri dates are a day and month\n" +
+					"name with an optional year, e.g. \"1 Ramadan 1447\", or YYYY-MM-DD with a year before 1600.\n" +
+					"Without a year the Hijri date is looked up in the current year. JAKIM's published dates are used\n" +
+					"when cached, otherwise the tabular Islamic calendar.",
+				Action: handleHijri(ctx),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "zone",
+						Usage: "Zone whose cached JAKIM dates are used (default: the default zone)",
+					},
+				},
+			},
+			{
+				Name:   "next",
+				Usage:  "Show the next prayer and the time left until it",
+				Action: handleNext(ctx),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "zone",
+						Usage: "Zone id (default: the default zone)",
+					},
+					&cli.BoolFlag{
+						Name:  "short",
+						Usage: "Print a single terse line for status bars, e.g. \"Asar 04:18PM -1:05\"",
+					},
+					&cli.DurationFlag{
+						Name:  "within",
+						Usage: "Exit with status 1 unless the next prayer starts within `DURATION`, for scripts",
+					},
+				},
+			},
+			{
+				Name:    "ramadan",
+				Aliases: []string{"puasa"},
+				Usage:   "Print the Ramadan fasting timetable (imsakiyah) with a countdown to iftar or sahur",
+				Action:  handleRamadan(ctx),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "zone",
+						Usage: "Zone id (default: the default zone)",
+					},
+					&cli.IntFlag{
+						Name:  "year",
+						Usage: "Hijri `YEAR` (default: the Ramadan of the current year)",
+					},
+				},
+			},
+			{
+				Name:  "offset",
+				Usage: "Adjust prayer times by a number of minutes, for every zone or one zone",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "set",
+						Usage:     "Set the offset of a prayer, negative minutes move it earlier, at most 60 either way",
+						ArgsUsage: "<prayer> <minutes>",
+						Action:    setOffset(ctx),
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "zone",
+								Usage: "Only adjust times for this `ZONE` (default: every zone)",
+							},
+						},
+					},
+					{
+						Name:   "list",
+						Usage:  "List offsets",
+						Action: listOffsets(ctx),
+					},
+					{
+						Name:      "unset",
+						Usage:     "Remove the offset of a prayer",
+						ArgsUsage: "<prayer>",
+						Action:    unsetOffset(ctx),
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  "zone",
+								Usage: "Remove the offset of this `ZONE` (default: the one for every zone)",
+							},
+						},
+					},
+				},
+			},
+			{
+				Name:   "serve",
+				Usage:  "Serve zones, prayer times and Prometheus /metrics over HTTP",
+				Action: handleServe(ctx),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:    "listen",
+						Value:   "127.0.0.1:8080",
+						Usage:   "`ADDRESS` to listen on",
+						EnvVars: []string{common.ENV_PREFIX + "LISTEN"},
+					},
+					&cli.StringSliceFlag{
+						Name:  "metrics-zone",
+						Usage: "`ZONE` exported by /metrics, can be repeated (default: the default zone)",
+					},
+				},
+			},
+			{
+				Name:  "config",
+				Usage: "Read and change the settings of the config file and its profiles",
+				Description: "Settings are read from the selected profile, then the top level of the config file, then the\n" +
+					"database of older versions. Flags and WS_ environment variables take precedence over them.\n" +
+					"Settings: " + strings.Join(configNames(), ", ") + ", offset.<prayer> and offset.<zone>.<prayer>.",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "get",
+						Usage:     "Print the value of a setting",
+						ArgsUsage: "<name>",
+						Action:    getConfig(ctx),
+						Flags:     []cli.Flag{configProfileFlag()},
+					},
+					{
+						Name:      "set",
+						Usage:     "Change a setting, a zone is picked interactively when omitted",
+						ArgsUsage: "<name> <value>",
+						Action:    setConfig(ctx),
+						Flags:     []cli.Flag{configProfileFlag()},
+					},
+					{
+						Name:   "list",
+						Usage:  "List every setting with where its value comes from",
+						Action: listConfig(ctx),
+						Flags:  []cli.Flag{configProfileFlag()},
+					},
+					{
+						Name:      "unset",
+						Usage:     "Remove a setting, going back to the top level value or the default",
+						ArgsUsage: "<name>",
+						Action:    unsetConfig(ctx),
+						Flags:     []cli.Flag{configProfileFlag()},
+					},
+				},
+			},
+			{
+				Name:      "set-provider",
+				Usage:     "Set default prayer time provider, same as config set provider",
+				Action:    setProvider(ctx),
+				ArgsUsage: "<provider>",
+			},
+			{
+				Name:      "set-time-format",
+				Usage:     "Set default time display format, 12h, 24h or a Go time layout such as \"3:04 pm\", same as config set time-format",
+				Action:    setTimeFormat(ctx),
+				ArgsUsage: "<format>",
+			},
+			{
+				Name:      "set-format",
+				Usage:     "Set the default template get and next are printed with in cli mode, omit it to go back to the built-in output",
+				Action:    setFormat(ctx),
+				ArgsUsage: "[template]",
 			},
 			{
 				Name:      "set-zone",
-				Usage:     "Set default zone id",
+				Usage:     "Set default zone id, same as config set zone, pick one interactively when omitted",
 				Action:    setZone(ctx),
-				ArgsUsage: "<zone-id>",
+				ArgsUsage: "[zone-id]",
 			},
 		},
 	}
@@ -96,22 +543,789 @@ func main() {
 }
 
 func setZone(ctx *common.Ctx) cli.ActionFunc {
-	check := func(zId string) error {
-		if len(zId) != 0 {
-			if services.GetZoneById(ctx, zId) != nil {
-				services.SetUserConfig(ctx, "ZONE_ID", zId)
-				log.Printf("Updated zone id: %s", zId)
-				return nil
+	return func(cli *cli.Context) error {
+		zId := cli.Args().First()
+		if len(zId) == 0 && isInteractive() && !ctx.Config.IsAlfred() {
+			var err error
+			if zId, err = pickZone(ctx, os.Stdin); err != nil || len(zId) == 0 {
+				return err
 			}
-		} else {
+		}
+		if len(zId) == 0 {
 			return fmt.Errorf("zone id argument is required")
 		}
-		return fmt.Errorf("zone with id [%s] not found", zId)
+		value, err := services.SetConfig(ctx, ctx.Config.Profile, services.ConfigZone, zId)
+		if err != nil {
+			return err
+		}
+		log.Printf("Updated zone id: %s", value.Value)
+		return nil
 	}
+}
+
+func isInteractive() bool {
+	stat, err := os.Stdin.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
 
+// pickZone prompts for a search text and lets the user choose one of the matching zones,
+// an empty answer cancels
+func pickZone(ctx *common.Ctx, in io.Reader) (string, error) {
+	const pageSize = 10
+	states := services.GetZoneStates(ctx)
+	reader := bufio.NewReader(in)
+	var matches services.ZoneMatches
+	prompt := common.T("Search zone (empty to cancel): ")
+	for {
+		fmt.Print(color.CyanString(prompt))
+		line, err := reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			return "", err
+		}
+		if n, convErr := strconv.Atoi(line); convErr == nil && n >= 1 && n <= len(matches) {
+			return matches[n-1].Zone.ID, nil
+		}
+		matches = services.SearchZones(states, line)
+		if len(matches) > pageSize {
+			matches = matches[:pageSize]
+		}
+		if len(matches) == 0 {
+			color.Red("%s", common.T("No zone matches [%s]", line))
+			prompt = common.T("Search zone (empty to cancel): ")
+			continue
+		}
+		for i, m := range matches {
+			color.White("%2d) %s - %s %s", i+1, color.CyanString(m.Zone.ID),
+				color.YellowString(m.Zone.Locations), color.BlueString("(%s)", m.State))
+		}
+		prompt = common.T("Select [1-%d], search again or empty to cancel: ", len(matches))
+	}
+}
+
+func searchZones(ctx *common.Ctx) cli.ActionFunc {
 	return func(cli *cli.Context) error {
-		zId := cli.Args().First()
-		return check(zId)
+		query := strings.Join(cli.Args().Slice(), " ")
+		if len(strings.TrimSpace(query)) == 0 {
+			return fmt.Errorf("search text argument is required")
+		}
+		matches := services.SearchZones(services.GetZoneStates(ctx), query)
+		if limit := cli.Int("limit"); limit > 0 && len(matches) > limit {
+			matches = matches[:limit]
+		}
+		switch {
+		case ctx.Config.IsAlfred():
+			res := matches.ToAlfredResponse()
+			res.Print()
+		case ctx.Config.IsJSON():
+			return printJSON(matches.ToJSONResponse())
+		default:
+			if len(matches) == 0 {
+				return fmt.Errorf("no zone matches [%s]", query)
+			}
+			for _, m := range matches {
+				color.White("%s - %s %s", color.CyanString(m.Zone.ID),
+					color.YellowString(m.Zone.Locations), color.BlueString("(%s)", m.State))
+			}
+		}
+		return nil
+	}
+}
+
+func handleExportICS(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		var alarms []time.Duration
+		for _, m := range cli.IntSlice("alarm") {
+			if m <= 0 {
+				return fmt.Errorf("invalid alarm [%d], expected minutes before the prayer greater than 0", m)
+			}
+			alarms = append(alarms, time.Duration(m)*time.Minute)
+		}
+		from, to, err := dateRange(cli)
+		if err != nil {
+			return err
+		}
+		prayerTimes := services.GetPrayerTimes(ctx, cli.String("zone"), from, to)
+		if len(prayerTimes) == 0 {
+			return fmt.Errorf("no prayer times found between %s and %s",
+				from.Format(services.InputDateLayout), to.Format(services.InputDateLayout))
+		}
+		opts := services.ICSOptions{
+			EventLength: cli.Duration("length"),
+			Prayers:     cli.StringSlice("prayers"),
+			Alarms:      alarms,
+		}
+		var w io.Writer = os.Stdout
+		if path := cli.String("out"); len(path) != 0 {
+			f, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			w = f
+		}
+		return services.WriteICS(w, prayerTimes, opts)
+	}
+}
+
+func handleWatch(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		scheduler := &services.Scheduler{
+			Ctx:     ctx,
+			ZoneID:  strings.ToUpper(cli.String("zone")),
+			Prayers: cli.StringSlice("prayers"),
+		}
+		for _, m := range cli.IntSlice("remind") {
+			scheduler.Offsets = append(scheduler.Offsets, time.Duration(m)*time.Minute)
+		}
+		var mqttNotifiers []*services.MQTTNotifier
+		for _, name := range cli.StringSlice("notify") {
+			switch name {
+			case "stdout":
+				scheduler.Notifiers = append(scheduler.Notifiers, &services.StreamNotifier{
+					Writer: os.Stdout,
+					IsJSON: ctx.Config.IsJSON(),
+				})
+			case "desktop":
+				n, err := services.NewDesktopNotifier()
+				if err != nil {
+					return err
+				}
+				scheduler.Notifiers = append(scheduler.Notifiers, n)
+			case "mqtt":
+				mqttNotifier, err := services.NewMQTTNotifier(ctx, scheduler.ZoneID, services.MQTTOptions{
+					Broker:          cli.String("mqtt-broker"),
+					ClientID:        cli.String("mqtt-client-id"),
+					Username:        cli.String("mqtt-username"),
+					Password:        cli.String("mqtt-password"),
+					Topic:           cli.String("mqtt-topic"),
+					DiscoveryPrefix: cli.String("mqtt-discovery"),
+				})
+				if err != nil {
+					return err
+				}
+				defer mqttNotifier.Close()
+				mqttNotifiers = append(mqttNotifiers, mqttNotifier)
+				scheduler.Notifiers = append(scheduler.Notifiers, mqttNotifier)
+			default:
+				return fmt.Errorf("unknown notifier [%s], expected one of stdout|desktop|mqtt", name)
+			}
+		}
+		scheduler.Notifiers = append(scheduler.Notifiers, &services.HookNotifier{
+			Ctx:    ctx,
+			DryRun: cli.Bool("dry-run"),
+		})
+		runCtx, stop := signal.NotifyContext(cli.Context, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		for _, n := range mqttNotifiers {
+			go n.Run(runCtx)
+		}
+		log.Printf("Watching prayer times, press Ctrl+C to stop")
+		return scheduler.Run(runCtx)
+	}
+}
+
+func handleServe(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		handler := services.NewServer(ctx)
+		handler.MetricsZones = cli.StringSlice("metrics-zone")
+		server := &http.Server{
+			Addr:              cli.String("listen"),
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		runCtx, stop := signal.NotifyContext(cli.Context, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		go func() {
+			<-runCtx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = server.Shutdown(shutdownCtx)
+		}()
+		log.Printf("Listening on http://%s", server.Addr)
+		if err := server.ListenAndServe(); err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	}
+}
+
+func addHook(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		command := strings.Join(cli.Args().Slice(), " ")
+		if len(command) == 0 {
+			return fmt.Errorf("command argument is required")
+		}
+		if cli.IsSet("before") && cli.IsSet("after") {
+			return fmt.Errorf("only one of --before and --after can be set")
+		}
+		hook := &services.Hook{
+			Prayer:  cli.String("prayer"),
+			Offset:  cli.Int("before") - cli.Int("after"),
+			Command: command,
+			Timeout: cli.Duration("timeout"),
+		}
+		if err := services.AddHook(ctx, hook); err != nil {
+			return err
+		}
+		log.Printf("Added hook #%d", hook.ID)
+		return nil
+	}
+}
+
+func listHooks(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		hooks := services.GetHooks(ctx)
+		if ctx.Config.IsJSON() {
+			return printJSON(hooks)
+		}
+		for _, h := range hooks {
+			when := common.T("at prayer time")
+			if h.Offset > 0 {
+				when = common.T("%d min before", h.Offset)
+			} else if h.Offset < 0 {
+				when = common.T("%d min after", -h.Offset)
+			}
+			color.White("#%d\t%s %s (%s)\t%s", h.ID, color.CyanString(h.Prayer),
+				color.YellowString(when), common.T("timeout %s", h.Timeout), h.Command)
+		}
+		return nil
+	}
+}
+
+// offsetZone resolves the --zone option of the offset commands, empty means every zone
+func offsetZone(ctx *common.Ctx, cli *cli.Context) (string, error) {
+	if !cli.IsSet("zone") {
+		return "", nil
+	}
+	zone := services.GetZoneById(ctx, cli.String("zone"))
+	if zone == nil {
+		return "", fmt.Errorf("zone with id [%s] not found", cli.String("zone"))
+	}
+	return zone.ID, nil
+}
+
+func setOffset(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		if cli.NArg() != 2 {
+			return fmt.Errorf("prayer and minutes arguments are required")
+		}
+		minutes, err := strconv.Atoi(cli.Args().Get(1))
+		if err != nil {
+			return fmt.Errorf("invalid minutes [%s]", cli.Args().Get(1))
+		}
+		key, err := services.NormalizePrayerKey(cli.Args().First())
+		if err != nil {
+			return err
+		}
+		zoneId, err := offsetZone(ctx, cli)
+		if err != nil {
+			return err
+		}
+		if err = services.SetOffset(ctx, zoneId, key, minutes); err != nil {
+			return err
+		}
+		log.Printf("Offset of %s set to %s", key, services.FormatOffset(time.Duration(minutes)*time.Minute))
+		return nil
+	}
+}
+
+func listOffsets(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		settings := services.GetOffsetSettings(ctx)
+		if ctx.Config.IsJSON() {
+			if settings == nil {
+				settings = []services.OffsetSetting{}
+			}
+			return printJSON(settings)
+		}
+		for _, o := range settings {
+			zone := common.Or(len(o.ZoneID) == 0, "all zones", o.ZoneID)
+			color.White("%s\t%s\t%s", color.CyanString(o.Prayer),
+				color.YellowString(services.FormatOffset(time.Duration(o.Minutes)*time.Minute)), zone)
+		}
+		return nil
+	}
+}
+
+func unsetOffset(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		if cli.NArg() != 1 {
+			return fmt.Errorf("prayer argument is required")
+		}
+		zoneId, err := offsetZone(ctx, cli)
+		if err != nil {
+			return err
+		}
+		return services.UnsetOffset(ctx, zoneId, cli.Args().First())
+	}
+}
+
+func removeHook(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		id, err := strconv.ParseUint(cli.Args().First(), 10, 32)
+		if err != nil {
+			return fmt.Errorf("hook id argument is required")
+		}
+		if err = services.RemoveHook(ctx, uint(id)); err != nil {
+			return err
+		}
+		log.Printf("Removed hook #%d", id)
+		return nil
+	}
+}
+
+func runHook(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		id, err := strconv.ParseUint(cli.Args().First(), 10, 32)
+		if err != nil {
+			return fmt.Errorf("hook id argument is required")
+		}
+		hook := services.GetHook(ctx, uint(id))
+		if hook == nil {
+			return fmt.Errorf("hook #%d not found", id)
+		}
+		zoneId := services.GetConfig(ctx, services.ConfigZone)
+		upcoming := services.UpcomingPrayers(ctx, zoneId, time.Now())
+		if len(upcoming) == 0 {
+			return fmt.Errorf("no upcoming prayer time found for zone [%s]", zoneId)
+		}
+		ev := services.Event{
+			Type:   services.EventPrayer,
+			Prayer: upcoming[0],
+			ZoneID: zoneId,
+			Zone:   services.GetZoneById(ctx, zoneId),
+			At:     time.Now(),
+		}
+		return hook.Run(ev)
+	}
+}
+
+func setProvider(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		name := strings.ToLower(cli.Args().First())
+		if len(name) == 0 {
+			return fmt.Errorf("provider argument is required")
+		}
+		if _, err := services.SetConfig(ctx, ctx.Config.Profile, services.ConfigProvider, name); err != nil {
+			return err
+		}
+		log.Printf("Updated provider: %s", name)
+		return nil
+	}
+}
+
+func setTimeFormat(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		format := cli.Args().First()
+		if len(format) == 0 {
+			return fmt.Errorf("format argument is required")
+		}
+		layout, err := services.ParseTimeFormat(format)
+		if err != nil {
+			return err
+		}
+		if _, err = services.SetConfig(ctx, ctx.Config.Profile, services.ConfigTimeFormat, format); err != nil {
+			return err
+		}
+		log.Printf("Updated time format: %s (%s)", format, time.Now().Format(layout))
+		return nil
+	}
+}
+
+// hijriGregorianYearMin separates Hijri YYYY-MM-DD dates from Gregorian ones
+const hijriGregorianYearMin = 1600
+
+func handleHijri(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		value := strings.Join(cli.Args().Slice(), " ")
+		var conversion services.HijriConversion
+		if date, err := services.ParseDate(value); err == nil && date.Year() >= hijriGregorianYearMin {
+			conversion = services.ToHijri(ctx, cli.String("zone"), date)
+		} else {
+			h, hijriErr := services.ParseHijri(value)
+			if hijriErr != nil {
+				return fmt.Errorf("invalid date [%s], expected a date or a hijri date such as 1 Ramadan", value)
+			}
+			conversion = services.FromHijri(ctx, cli.String("zone"), h.InGregorianYear(services.Today().Year()))
+		}
+		switch {
+		case ctx.Config.IsJSON():
+			return printJSON(conversion.ToJSONResponse())
+		case ctx.Config.IsAlfred():
+			subtitle := fmt.Sprintf("%s | %s", conversion.Hijri.String(), conversion.Source)
+			res := common.AlfredResponse{}
+			res.AddItem(common.AlfredResponseItem{
+				Title:    fmt.Sprintf("%s | %s", conversion.Date.Format(services.PrimaryDateLayout), conversion.Hijri.Format()),
+				Subtitle: &subtitle,
+				Arg:      conversion.Date.Format(services.InputDateLayout),
+				Valid:    true,
+			})
+			res.Print()
+		default:
+			source := common.Or(conversion.Source == services.HijriSourceJakim, "", color.WhiteString(" (%s)", common.T("calculated")))
+			color.White("%s = %s%s", color.CyanString(conversion.Date.Format(services.PrimaryDateLayout)),
+				color.MagentaString(conversion.Hijri.Format()), source)
+		}
+		return nil
+	}
+}
+
+func printTemplate(format string, data services.TemplateData) error {
+	res, err := services.ExecuteTemplate(format, data)
+	if err != nil {
+		return err
+	}
+	fmt.Print(res)
+	return nil
+}
+
+func setFormat(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		format := cli.Args().First()
+		if len(format) == 0 {
+			if err := services.UnsetConfig(ctx, ctx.Config.Profile, services.ConfigFormat); err != nil {
+				return err
+			}
+			log.Printf("Removed default format")
+			return nil
+		}
+		if _, err := services.SetConfig(ctx, ctx.Config.Profile, services.ConfigFormat, format); err != nil {
+			return err
+		}
+		log.Printf("Updated default format: %s", format)
+		return nil
+	}
+}
+
+func configNames() []string {
+	var res []string
+	for _, s := range services.Settings() {
+		res = append(res, s.Name)
+	}
+	return res
+}
+
+func configProfileFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:  "profile",
+		Usage: "Read or change this `PROFILE`, it is created when missing (default: the global --profile, or the top level settings)",
+	}
+}
+
+// configProfile is the profile a config command reads or changes, its --profile or the global one
+func configProfile(ctx *common.Ctx, cli *cli.Context) string {
+	if profile := cli.String("profile"); len(profile) != 0 {
+		return profile
+	}
+	return ctx.Config.Profile
+}
+
+func getConfig(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		if cli.NArg() != 1 {
+			return fmt.Errorf("setting name argument is required")
+		}
+		value, err := services.GetConfigValue(ctx, configProfile(ctx, cli), cli.Args().First())
+		if err != nil {
+			return err
+		}
+		if ctx.Config.IsJSON() {
+			return printJSON(value)
+		}
+		fmt.Println(value.Value)
+		return nil
+	}
+}
+
+func setConfig(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		name, value := cli.Args().First(), cli.Args().Get(1)
+		if cli.NArg() == 1 && strings.EqualFold(name, services.ConfigZone) && isInteractive() && !ctx.Config.IsAlfred() {
+			var err error
+			if value, err = pickZone(ctx, os.Stdin); err != nil || len(value) == 0 {
+				return err
+			}
+		} else if cli.NArg() != 2 {
+			return fmt.Errorf("setting name and value arguments are required")
+		}
+		stored, err := services.SetConfig(ctx, configProfile(ctx, cli), name, value)
+		if err != nil {
+			return err
+		}
+		log.Printf("Updated %s: %s (%s)", stored.Name, stored.Value, stored.Source)
+		return nil
+	}
+}
+
+func listConfig(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		values, err := services.ListConfig(ctx, configProfile(ctx, cli))
+		if err != nil {
+			return err
+		}
+		if ctx.Config.IsJSON() {
+			return printJSON(values)
+		}
+		file, err := services.ReadConfigFile(ctx.Config.ConfigPath)
+		if err != nil {
+			return err
+		}
+		color.White("%s\t%s", color.CyanString("File"), ctx.Config.ConfigPath)
+		if profiles := file.ProfileNames(); len(profiles) != 0 {
+			color.White("%s\t%s", color.CyanString("Profiles"), strings.Join(profiles, ", "))
+		}
+		width := 0
+		for _, v := range values {
+			if n := utf8.RuneCountInString(v.Name); n > width {
+				width = n
+			}
+		}
+		for _, v := range values {
+			color.White("%s  %s\t%s", color.CyanString(padRight(v.Name, width)), color.YellowString(v.Value), v.Source)
+		}
+		return nil
+	}
+}
+
+func unsetConfig(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		if cli.NArg() != 1 {
+			return fmt.Errorf("setting name argument is required")
+		}
+		if err := services.UnsetConfig(ctx, configProfile(ctx, cli), cli.Args().First()); err != nil {
+			return err
+		}
+		value, err := services.GetConfigValue(ctx, configProfile(ctx, cli), cli.Args().First())
+		if err != nil {
+			return err
+		}
+		log.Printf("Removed %s, now %s (%s)", value.Name, value.Value, value.Source)
+		return nil
+	}
+}
+
+// exitStatus ends the program with code without printing anything
+func exitStatus(code int) error {
+	return cli.Exit("", code)
+}
+
+func handleNext(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		now := time.Now()
+		next, err := services.GetNextPrayer(ctx, cli.String("zone"), now)
+		format := services.OutputTemplate(ctx)
+		switch {
+		case err == nil && len(format) != 0 && !cli.Bool("short"):
+			if err = printTemplate(format, services.NewTemplateData(nil, next, now)); err != nil {
+				return err
+			}
+		case ctx.Config.IsAlfred():
+			res := common.AlfredResponse{}
+			if err != nil {
+				res.AddItem(*common.AlfredWarning(err.Error(), nil))
+			} else {
+				res = next.ToAlfredResponse()
+			}
+			res.Print()
+			return nil
+		case err != nil:
+			return err
+		case ctx.Config.IsJSON():
+			if err = printJSON(next.ToJSONResponse()); err != nil {
+				return err
+			}
+		case ctx.Config.IsStatusBar():
+			fmt.Println(next.StatusLine(ctx.Config.Mode))
+		case cli.Bool("short"):
+			fmt.Println(next.Short())
+		default:
+			color.White("%s %s", color.CyanString(next.Summary()), color.YellowString("(%s%s)", next.Next.DisplayValue, next.Next.AdjustmentNote()))
+			if next.Current != nil {
+				color.White("%s\t: %s %s", padRight(common.T("Current"), 8), next.Current.Name(), next.Current.DisplayValue)
+			}
+			if next.Zone != nil {
+				color.Blue("%s\t: %s", padRight(common.T("Locations"), 8), next.Zone.Locations)
+			}
+			if next.PrayerDate != nil && next.PrayerDate.Calculated {
+				color.Magenta("* %s", next.PrayerDate.CalculatedNote())
+			}
+		}
+		if within := cli.Duration("within"); within > 0 && next.Remaining > within {
+			return exitStatus(1)
+		}
+		return nil
+	}
+}
+
+func handleRamadan(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		year := cli.Int("year")
+		if year == 0 {
+			year = services.CurrentRamadanYear()
+		}
+		ramadan, err := services.GetRamadan(ctx, cli.String("zone"), year)
+		now := time.Now()
+		switch {
+		case ctx.Config.IsAlfred():
+			if err != nil {
+				res := common.AlfredResponse{}
+				res.AddItem(*common.AlfredWarning(err.Error(), nil))
+				res.Print()
+				return nil
+			}
+			res := ramadan.ToAlfredResponse(now)
+			res.Print()
+		case err != nil:
+			return err
+		case ctx.Config.IsJSON():
+			return printJSON(ramadan.ToJSONResponse(now))
+		default:
+			printRamadan(ramadan, now)
+		}
+		return nil
+	}
+}
+
+// printRamadan prints the fasting timetable, one row per day
+func printRamadan(ramadan *services.Ramadan, now time.Time) {
+	color.Blue("%s %d", services.HijriMonthName(services.RamadanMonth), ramadan.Year)
+	if ramadan.Zone != nil {
+		color.Blue("%s : %s", padRight(common.T("Locations"), 9), ramadan.Zone.Locations)
+	}
+	if ramadan.Source != services.HijriSourceJakim {
+		color.Yellow(common.T("Start and end dates are calculated, they may differ from JAKIM's announcement"))
+	}
+	if c, ok := ramadan.Countdown(now); ok {
+		color.Red(c.Describe())
+	}
+	first := ramadan.Days[0]
+	width := 7
+	for _, d := range ramadan.Days {
+		for _, pt := range []services.PrayTime{d.Imsak, d.Subuh, d.Maghrib} {
+			if n := utf8.RuneCountInString(pt.DisplayValue + common.Or(pt.IsAdjusted(), "*", "")); n > width {
+				width = n
+			}
+		}
+	}
+	header := fmt.Sprintf("%s  %s", padRight("#", 3), padRight(common.T("Date"), 10))
+	for _, pt := range []services.PrayTime{first.Imsak, first.Subuh, first.Maghrib} {
+		header += "  " + padRight(pt.Name(), width)
+	}
+	color.Cyan(header + "  " + common.T("Fasting"))
+	today := services.Today().Format(services.PrimaryDateLayout)
+	for _, d := range ramadan.Days {
+		row := fmt.Sprintf("%-3d  %-10s", d.Day, d.Date)
+		for _, pt := range []services.PrayTime{d.Imsak, d.Subuh, d.Maghrib} {
+			row += "  " + color.YellowString(padRight(pt.DisplayValue+common.Or(pt.IsAdjusted(), "*", ""), width))
+		}
+		row += "  " + common.Timespan(d.Length()).Format()
+		if d.Date == today {
+			row += color.RedString(" *%s", common.T("Today"))
+		}
+		color.White(row)
+	}
+}
+
+func handleLocate(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		location, err := services.LocateZone(cli.Float64("lat"), cli.Float64("lon"))
+		if err != nil {
+			if ctx.Config.IsAlfred() {
+				res := common.AlfredResponse{}
+				res.AddItem(*common.AlfredWarning(common.T("Zone not found"), common.Ptr(err.Error())))
+				res.Print()
+				return nil
+			}
+			return err
+		}
+		zone := services.GetZoneById(ctx, location.ZoneID)
+		locations := ""
+		if zone != nil {
+			locations = zone.Locations
+		}
+		switch {
+		case ctx.Config.IsJSON():
+			return printJSON(struct {
+				*services.Location
+				Locations string `json:"locations"`
+			}{location, locations})
+		case ctx.Config.IsAlfred():
+			subtitle := fmt.Sprintf("%s | %s (%.1f km)", location.ZoneID, common.T("near %s", location.District), location.Distance)
+			res := common.AlfredResponse{}
+			res.AddItem(common.AlfredResponseItem{
+				Title:    locations,
+				Subtitle: &subtitle,
+				Arg:      location.ZoneID,
+				Valid:    true,
+				Variables: map[string]string{
+					"location": locations,
+				},
+			})
+			res.Print()
+		default:
+			color.White("%s - %s", color.CyanString(location.ZoneID), color.YellowString(locations))
+			color.White("%s\t: %s (%.1f km)", common.T("Nearest"), location.District, location.Distance)
+		}
+		return nil
+	}
+}
+
+func handleUpdate(ctx *common.Ctx) cli.ActionFunc {
+	return func(cli *cli.Context) error {
+		failed := 0
+		changes, err := services.UpdateZones(ctx)
+		if err != nil {
+			failed++
+			color.Red("%s\t: %s", common.T("Zones"), err)
+		} else {
+			for _, z := range changes.Added {
+				color.Green("%s\t: %s", common.T("Zones"), common.T("added %s - %s", z.ID, z.Locations))
+			}
+			for _, z := range changes.Renamed {
+				color.Yellow("%s\t: %s", common.T("Zones"), common.T("renamed %s - %s", z.ID, z.Locations))
+			}
+			for _, z := range changes.Removed {
+				color.Red("%s\t: %s", common.T("Zones"), common.T("removed %s - %s", z.ID, z.Locations))
+			}
+			if len(changes.Added)+len(changes.Renamed)+len(changes.Removed) == 0 {
+				color.White("%s\t: %s", common.T("Zones"), common.T("up to date"))
+			}
+		}
+
+		zoneIds := cli.Args().Slice()
+		if cli.Bool("all") {
+			zoneIds = nil
+			for _, state := range services.GetZoneStates(ctx) {
+				for _, zone := range state.Zones {
+					zoneIds = append(zoneIds, zone.ID)
+				}
+			}
+		} else if len(zoneIds) == 0 {
+			zoneIds = []string{services.GetConfig(ctx, services.ConfigZone)}
+		}
+		for _, res := range services.UpdatePrayerTimes(ctx, zoneIds, cli.Bool("force")) {
+			label := color.CyanString("%s %d", res.ZoneID, res.Year)
+			switch {
+			case res.Err != nil:
+				failed++
+				color.White("%s\t: %s", label, color.RedString("%s", common.T("failed, %s", res.Err)))
+			case res.Skipped && res.Count == 0:
+				color.White("%s\t: %s", label, color.YellowString("%s", common.T("not available, retried recently")))
+			case res.Skipped:
+				color.White("%s\t: %s", label, common.T("up to date (%d days)", res.Count))
+			case res.Count == 0:
+				color.White("%s\t: %s", label, color.YellowString("%s", common.T("not published yet")))
+			case res.Changed:
+				color.White("%s\t: %s", label, color.GreenString("%s", common.T("updated (%d days)", res.Count)))
+			default:
+				color.White("%s\t: %s", label, common.T("unchanged (%d days)", res.Count))
+			}
+		}
+		if failed > 0 {
+			return fmt.Errorf("%s", common.T("%d update(s) failed", failed))
+		}
+		return nil
 	}
 }
 
@@ -122,9 +1336,12 @@ func handleZones(ctx *common.Ctx) func(cli *cli.Context) error {
 			zs := services.ZoneStates(states)
 			res, _ := json.Marshal(zs.ToAlfredResponse())
 			fmt.Print(string(res))
+		} else if ctx.Config.IsJSON() {
+			zs := services.ZoneStates(states)
+			return printJSON(zs.ToJSONResponse())
 		} else {
 			for _, state := range states {
-				color.Blue("State: %s", state.Name)
+				color.Blue("%s: %s", common.T("State"), state.Name)
 				for _, zone := range state.Zones {
 					color.White("%s - %s", color.CyanString(zone.ID), color.YellowString(zone.Locations))
 				}
@@ -137,7 +1354,65 @@ func handleZones(ctx *common.Ctx) func(cli *cli.Context) error {
 
 func handlePrayerTimes(ctx *common.Ctx) func(cli *cli.Context) error {
 	return func(cli *cli.Context) error {
-		prayerTimes := services.GetPrayerTimes(ctx, cli.String("zone"), cli.String("mode"))
+		format := services.OutputTemplate(ctx)
+		if ctx.Config.IsStatusBar() && len(format) == 0 {
+			// Status bars only show the next prayer
+			next, err := services.GetNextPrayer(ctx, cli.String("zone"), time.Now())
+			if err != nil {
+				return err
+			}
+			fmt.Println(next.StatusLine(ctx.Config.Mode))
+			return nil
+		}
+		from, to, err := dateRange(cli)
+		if err != nil {
+			return err
+		}
+		var prayerTimes []services.PrayerDate
+		zoneId := cli.String("zone")
+		if cli.IsSet("lat") || cli.IsSet("lon") {
+			if !cli.IsSet("lat") || !cli.IsSet("lon") {
+				return fmt.Errorf("both --lat and --lon are required")
+			}
+			if location, err := services.LocateZone(cli.Float64("lat"), cli.Float64("lon")); err == nil {
+				zoneId = location.ZoneID
+				prayerTimes = services.GetPrayerTimes(ctx, zoneId, from, to)
+			} else if prayerTimes, err = services.GetPrayerTimesAt(ctx, cli.Float64("lat"), cli.Float64("lon"), from, to); err != nil {
+				return err
+			}
+		} else {
+			prayerTimes = services.GetPrayerTimes(ctx, zoneId, from, to)
+		}
+		if len(prayerTimes) == 0 {
+			if len(zoneId) == 0 {
+				zoneId = services.GetConfig(ctx, services.ConfigZone)
+			}
+			return fmt.Errorf("%s", common.T("no prayer times found for zone [%s]", strings.ToUpper(zoneId)))
+		}
+		if len(format) != 0 {
+			var next *services.NextPrayer
+			if len(prayerTimes) != 0 && len(prayerTimes[0].ZoneID) != 0 {
+				next, _ = services.GetNextPrayer(ctx, prayerTimes[0].ZoneID, time.Now())
+			}
+			return printTemplate(format, services.NewTemplateData(prayerTimes, next, time.Now()))
+		}
+		if ctx.Config.IsJSON() {
+			pts := services.PrayerDates(prayerTimes)
+			if len(prayerTimes) == 1 {
+				return printJSON(prayerTimes[0].ToJSONResponse())
+			}
+			return printJSON(pts.ToJSONResponse())
+		}
+		if len(prayerTimes) > 1 {
+			if ctx.Config.IsAlfred() {
+				pts := services.PrayerDates(prayerTimes)
+				res, _ := json.Marshal(pts.ToAlfredResponse())
+				fmt.Print(string(res))
+			} else {
+				printPrayerTable(prayerTimes)
+			}
+			return nil
+		}
 		if prayerTimes != nil {
 			for _, pt := range prayerTimes {
 				if ctx.Config.IsAlfred() {
@@ -146,16 +1421,25 @@ func handlePrayerTimes(ctx *common.Ctx) func(cli *cli.Context) error {
 					fmt.Print(string(jsonResponse))
 					return nil
 				} else {
-					color.Blue("Date\t\t: %s %s", pt.Date, color.MagentaString(pt.Hijri))
-					color.Blue("Locations\t: %s", pt.Zone.Locations)
+					color.Blue("%s\t: %s %s", padRight(common.T("Date"), 8), pt.Date, color.MagentaString(pt.HijriDisplay()))
+					color.Blue("%s\t: %s", padRight(common.T("Locations"), 8), pt.Zone.Locations)
+					if tz := displayTimezone(pt); len(tz) != 0 {
+						color.Blue("%s\t: %s", padRight(common.T("Timezone"), 8), tz)
+					}
+					if note := pt.CalculatedNote(); len(note) != 0 {
+						color.Magenta("* %s", note)
+					}
 					for _, t := range pt.Times {
 						var desc string
 						if t.IsCurrent {
-							desc = color.RedString("*Current")
+							desc = color.RedString("*%s", common.T("Current"))
 						} else if d := t.Time.Sub(time.Now()); d > 0 {
 							desc = color.WhiteString("%s", common.Timespan(d).Format())
 						}
-						color.White("%s\t: %s %s", color.CyanString(t.Key), color.YellowString(t.DisplayValue), desc)
+						if t.IsAdjusted() {
+							desc = color.MagentaString("*%s ", common.T("Adjusted %s", services.FormatOffset(t.Offset))) + desc
+						}
+						color.White("%s\t: %s %s", color.CyanString(t.Name()), color.YellowString(t.DisplayValue), desc)
 					}
 				}
 			}
@@ -163,3 +1447,117 @@ func handlePrayerTimes(ctx *common.Ctx) func(cli *cli.Context) error {
 		return nil
 	}
 }
+
+func printJSON(v any) error {
+	res, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(res))
+	return nil
+}
+
+// dateRangeFlags are the options read by dateRange
+func dateRangeFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "mode",
+			Aliases: []string{},
+			Value:   "daily",
+			Usage:   "Result mode (daily|weekly|monthly|yearly)",
+		},
+		&cli.StringFlag{
+			Name:  "date",
+			Usage: "Reference `DATE` for --mode, YYYY-MM-DD or DD/MM/YYYY (default: today)",
+		},
+		&cli.StringFlag{
+			Name:  "from",
+			Usage: "First `DATE` of a custom range, overrides --mode",
+		},
+		&cli.StringFlag{
+			Name:  "to",
+			Usage: "Last `DATE` of a custom range (default: same as --from)",
+		},
+	}
+}
+
+// dateRange resolves the --date, --mode, --from and --to options into an inclusive range of days
+func dateRange(cli *cli.Context) (time.Time, time.Time, error) {
+	return services.DateRange(cli.String("date"), cli.String("mode"), cli.String("from"), cli.String("to"))
+}
+
+// displayTimezone describes the time zone times are shown in, empty when they are in Malaysian time
+func displayTimezone(p services.PrayerDate) string {
+	if len(p.Times) == 0 || p.Times[0].Time.Location() == services.JakimLocation {
+		return ""
+	}
+	t := p.Times[0].Time
+	return fmt.Sprintf("%s (%s)", t.Location(), t.Format("MST -07:00"))
+}
+
+// padRight pads s with spaces to width characters
+func padRight(s string, width int) string {
+	if n := utf8.RuneCountInString(s); n < width {
+		return s + strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// printPrayerTable prints multiple days as a compact table, one row per day
+func printPrayerTable(prayerTimes []services.PrayerDate) {
+	first := prayerTimes[0]
+	if first.Zone != nil {
+		color.Blue("%s : %s", padRight(common.T("Locations"), 9), first.Zone.Locations)
+	}
+	if tz := displayTimezone(first); len(tz) != 0 {
+		color.Blue("%s : %s", padRight(common.T("Timezone"), 9), tz)
+	}
+	// Column width fits the widest of the prayer names and the formatted times
+	width := 7
+	for _, pt := range prayerTimes {
+		for _, t := range pt.Times {
+			// Adjusted times are followed by a * marker
+			if n := utf8.RuneCountInString(t.DisplayValue + common.Or(t.IsAdjusted(), "*", "")); n > width {
+				width = n
+			}
+			if n := utf8.RuneCountInString(t.Name()); n > width {
+				width = n
+			}
+		}
+	}
+	header := fmt.Sprintf("%s  %s", padRight(common.T("Date"), 10), padRight(common.T("Hijri"), 10))
+	for _, t := range first.Times {
+		header += "  " + padRight(t.Name(), width)
+	}
+	color.Cyan(header)
+	today := services.Today().Format(services.PrimaryDateLayout)
+	for _, pt := range prayerTimes {
+		row := fmt.Sprintf("%-10s  %s", pt.Date, color.MagentaString("%-10s", pt.Hijri))
+		for _, t := range pt.Times {
+			if t.IsAdjusted() {
+				row += fmt.Sprintf("  %s%s", color.YellowString(t.DisplayValue), color.MagentaString(padRight("*", width-utf8.RuneCountInString(t.DisplayValue))))
+			} else {
+				row += fmt.Sprintf("  %s", color.YellowString(padRight(t.DisplayValue, width)))
+			}
+		}
+		if pt.Date == today {
+			row += color.RedString(" *%s", common.T("Today"))
+		}
+		color.White(row)
+	}
+	var adjusted []string
+	for _, t := range first.Times {
+		if t.IsAdjusted() {
+			adjusted = append(adjusted, fmt.Sprintf("%s %s", t.Name(), services.FormatOffset(t.Offset)))
+		}
+	}
+	if len(adjusted) != 0 {
+		color.Magenta("* %s", common.T("adjusted from the official JAKIM time: %s", strings.Join(adjusted, ", ")))
+	}
+	for _, pt := range prayerTimes {
+		if pt.Calculated {
+			color.Magenta("* %s", pt.CalculatedNote())
+			break
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package services
 
-import "github.com/sayuthisobri/waktu-solat/common"
+import (
+	"github.com/sayuthisobri/waktu-solat/common"
+	"log"
+)
 
 type UserConfig struct {
 	ID    string
@@ -8,7 +11,11 @@ type UserConfig struct {
 }
 
 func GetUserConfig(ctx *common.Ctx, key string, fallback string) string {
-	db, _ := OpenDb(ctx)
+	db, err := OpenDb(ctx)
+	if err != nil {
+		log.Println(err)
+		return fallback
+	}
 	uc := &UserConfig{}
 	tx := db.First(uc, "id=?", key)
 	if tx.Error == nil && len(uc.Value) != 0 {
@@ -18,10 +25,35 @@ func GetUserConfig(ctx *common.Ctx, key string, fallback string) string {
 }
 
 func SetUserConfig(ctx *common.Ctx, key string, value string) {
-	db, _ := OpenDb(ctx)
+	db, err := OpenDb(ctx)
+	if err != nil {
+		log.Println(err)
+		return
+	}
 	uc := UserConfig{
 		ID:    key,
 		Value: value,
 	}
 	db.Save(&uc)
 }
+
+func DeleteUserConfig(ctx *common.Ctx, key string) {
+	db, err := OpenDb(ctx)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	db.Delete(&UserConfig{}, "id=?", key)
+}
+
+// GetUserConfigs returns every stored config whose key starts with prefix
+func GetUserConfigs(ctx *common.Ctx, prefix string) []UserConfig {
+	db, err := OpenDb(ctx)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	var res []UserConfig
+	db.Where("id LIKE ?", prefix+"%").Order("id").Find(&res)
+	return res
+}
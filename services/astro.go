@@ -0,0 +1,155 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Calculation parameters used by JAKIM
+const (
+	FajrAngle    = 20.0
+	IshaAngle    = 18.0
+	SunriseAngle = 0.833
+	// AsrShadowFactor of 1 is the Shafi'i method
+	AsrShadowFactor = 1.0
+	// Ihtiyat is the precautionary margin added to each prayer, and removed from syuruk
+	Ihtiyat = 2 * time.Minute
+	// ImsakBeforeSubuh is how long imsak starts before subuh
+	ImsakBeforeSubuh = 10 * time.Minute
+)
+
+// CalculatePrayerDate computes a day's prayer times at the given coordinates,
+// the time zone of date is used for the resulting times
+func CalculatePrayerDate(lat float64, lon float64, date time.Time) PrayerDate {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, date.Location())
+	_, offset := day.Add(12 * time.Hour).Zone()
+	a := &astro{
+		lat: lat,
+		lon: lon,
+		tz:  float64(offset) / 3600,
+		jd:  julianDate(day.Year(), int(day.Month()), day.Day()) - lon/(15*24),
+	}
+
+	// Start from rough guesses and refine, each pass uses the sun position at the previous estimate
+	fajr, sunrise, dhuhr, asr, sunset, isha := 5.0, 6.0, 12.0, 13.0, 18.0, 18.0
+	for i := 0; i < 2; i++ {
+		fajr = a.sunAngleTime(FajrAngle, fajr, true)
+		sunrise = a.sunAngleTime(SunriseAngle, sunrise, true)
+		dhuhr = a.midDay(dhuhr)
+		asr = a.asrTime(AsrShadowFactor, asr)
+		sunset = a.sunAngleTime(SunriseAngle, sunset, false)
+		isha = a.sunAngleTime(IshaAngle, isha, false)
+	}
+
+	at := func(hours float64, adjust time.Duration, roundUp bool) time.Time {
+		t := day.Add(time.Duration((hours + a.tz - lon/15) * float64(time.Hour))).Add(adjust)
+		if roundUp && t.Truncate(time.Minute) != t {
+			return t.Truncate(time.Minute).Add(time.Minute)
+		}
+		return t.Truncate(time.Minute)
+	}
+	subuh := at(fajr, Ihtiyat, true)
+	format := func(t time.Time) string {
+		return t.Format(StoredTimeLayout)
+	}
+	return PrayerDate{
+		Date:    day.Format(PrimaryDateLayout),
+		Hijri:   TabularHijri(day).String(),
+		Imsak:   format(subuh.Add(-ImsakBeforeSubuh)),
+		Subuh:   format(subuh),
+		Syuruk:  format(at(sunrise, -Ihtiyat, false)),
+		Zohor:   format(at(dhuhr, Ihtiyat, true)),
+		Asar:    format(at(asr, Ihtiyat, true)),
+		Maghrib: format(at(sunset, Ihtiyat, true)),
+		Isyak:   format(at(isha, Ihtiyat, true)),
+	}
+}
+
+// ValidateCoordinate checks that lat and lon are within range
+func ValidateCoordinate(lat float64, lon float64) error {
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return fmt.Errorf("invalid coordinate [%f, %f]", lat, lon)
+	}
+	// Above the polar circles the sun may not reach the angles required for subuh or isyak
+	if math.Abs(lat) > 48 {
+		return fmt.Errorf("latitude [%f] is too far from the equator for these calculation parameters", lat)
+	}
+	return nil
+}
+
+type astro struct {
+	lat float64
+	lon float64
+	tz  float64
+	jd  float64
+}
+
+// sunPosition returns the sun declination (degrees) and equation of time (hours) for a julian date
+func sunPosition(jd float64) (float64, float64) {
+	d := jd - 2451545.0
+	g := fixAngle(357.529 + 0.98560028*d)
+	q := fixAngle(280.459 + 0.98564736*d)
+	l := fixAngle(q + 1.915*dSin(g) + 0.020*dSin(2*g))
+	e := 23.439 - 0.00000036*d
+	ra := dAtan2(dCos(e)*dSin(l), dCos(l)) / 15
+	eqt := q/15 - fixHour(ra)
+	decl := dAsin(dSin(e) * dSin(l))
+	return decl, eqt
+}
+
+func (a *astro) midDay(t float64) float64 {
+	_, eqt := sunPosition(a.jd + t/24)
+	return fixHour(12 - eqt)
+}
+
+func (a *astro) sunAngleTime(angle float64, t float64, ccw bool) float64 {
+	decl, _ := sunPosition(a.jd + t/24)
+	noon := a.midDay(t)
+	v := (-dSin(angle) - dSin(decl)*dSin(a.lat)) / (dCos(decl) * dCos(a.lat))
+	d := dAcos(math.Max(-1, math.Min(1, v))) / 15
+	if ccw {
+		return noon - d
+	}
+	return noon + d
+}
+
+func (a *astro) asrTime(factor float64, t float64) float64 {
+	decl, _ := sunPosition(a.jd + t/24)
+	angle := -dAtan(1 / (factor + dTan(math.Abs(a.lat-decl))))
+	return a.sunAngleTime(angle, t, false)
+}
+
+func julianDate(year int, month int, day int) float64 {
+	if month <= 2 {
+		year--
+		month += 12
+	}
+	a := math.Floor(float64(year) / 100)
+	b := 2 - a + math.Floor(a/4)
+	return math.Floor(365.25*float64(year+4716)) + math.Floor(30.6001*float64(month+1)) + float64(day) + b - 1524.5
+}
+
+func dSin(d float64) float64      { return math.Sin(d * math.Pi / 180) }
+func dCos(d float64) float64      { return math.Cos(d * math.Pi / 180) }
+func dTan(d float64) float64      { return math.Tan(d * math.Pi / 180) }
+func dAsin(x float64) float64     { return math.Asin(x) * 180 / math.Pi }
+func dAcos(x float64) float64     { return math.Acos(x) * 180 / math.Pi }
+func dAtan(x float64) float64     { return math.Atan(x) * 180 / math.Pi }
+func dAtan2(y, x float64) float64 { return math.Atan2(y, x) * 180 / math.Pi }
+
+func fixAngle(a float64) float64 {
+	a = a - 360*math.Floor(a/360)
+	if a < 0 {
+		a += 360
+	}
+	return a
+}
+
+func fixHour(h float64) float64 {
+	h = h - 24*math.Floor(h/24)
+	if h < 0 {
+		h += 24
+	}
+	return h
+}
@@ -0,0 +1,460 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Setting names, used as keys of the config file and by the config command
+const (
+	ConfigZone       = "zone"
+	ConfigProvider   = "provider"
+	ConfigTimeFormat = "time-format"
+	ConfigTimezone   = "tz"
+	ConfigLanguage   = "lang"
+	ConfigOutput     = "output"
+	ConfigFormat     = "format"
+
+	configProfilesKey = "profiles"
+)
+
+// Where a ConfigValue comes from, profiles are reported as "profile <name>"
+const (
+	ConfigSourceFile     = "file"
+	ConfigSourceDatabase = "database"
+	ConfigSourceDefault  = "default"
+)
+
+// Setting is a value stored in the config file
+type Setting struct {
+	Name string
+	// Key is the UserConfig key the setting was stored under before the config file, still read
+	// when the file doesn't set it
+	Key     string
+	Default string
+	Usage   string
+	// normalize validates a value and returns the one to store, strings are stored as is
+	normalize func(ctx *common.Ctx, value string) (any, error)
+}
+
+var settings = []Setting{
+	{Name: ConfigZone, Key: "ZONE_ID", Default: "WLY01", Usage: "default zone id", normalize: normalizeZone},
+	{Name: ConfigProvider, Key: "PROVIDER", Default: DefaultProvider, Usage: "prayer time provider", normalize: normalizeProvider},
+	{Name: ConfigTimeFormat, Key: "TIME_FORMAT", Default: TimeFormat12h, Usage: "12h, 24h or a Go time layout", normalize: normalizeTimeFormat},
+	{Name: ConfigTimezone, Usage: "timezone times are shown in, an IANA name or local, empty for Malaysian time", normalize: normalizeTimezone},
+	{Name: ConfigLanguage, Usage: "language of prayer names and messages, empty to detect it from the locale", normalize: normalizeLanguage},
+	{Name: ConfigOutput, Default: "cli", Usage: "output mode", normalize: normalizeOutput},
+	{Name: ConfigFormat, Key: "FORMAT", Usage: "template get and next are printed with in cli mode", normalize: normalizeFormat},
+}
+
+// Settings lists the fixed settings, the offset.<prayer> and offset.<zone>.<prayer> settings are
+// looked up with LookupSetting
+func Settings() []Setting {
+	return settings
+}
+
+// LookupSetting returns the setting of a name given in any case
+func LookupSetting(name string) (Setting, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, s := range settings {
+		if s.Name == name {
+			return s, nil
+		}
+	}
+	if zoneId, key, ok := parseOffsetSettingName(name); ok {
+		return Setting{
+			Name:      offsetSettingName(zoneId, key),
+			Key:       offsetConfigKey(zoneId, key),
+			Default:   "0",
+			Usage:     "minutes added to " + key + common.Or(len(zoneId) == 0, "", " in "+zoneId),
+			normalize: normalizeMinutes,
+		}, nil
+	}
+	var names []string
+	for _, s := range settings {
+		names = append(names, s.Name)
+	}
+	return Setting{}, fmt.Errorf("unknown setting [%s], expected one of %s, offset.<prayer> or offset.<zone>.<prayer>",
+		name, strings.Join(names, "|"))
+}
+
+func normalizeZone(ctx *common.Ctx, value string) (any, error) {
+	zone := GetZoneById(ctx, value)
+	if zone == nil {
+		return nil, fmt.Errorf("zone with id [%s] not found", value)
+	}
+	return zone.ID, nil
+}
+
+func normalizeProvider(ctx *common.Ctx, value string) (any, error) {
+	name := strings.ToLower(value)
+	if _, ok := providers[name]; !ok {
+		return nil, fmt.Errorf("provider [%s] not found, expected one of %s", value, strings.Join(ProviderNames(), "|"))
+	}
+	return name, nil
+}
+
+func normalizeTimeFormat(_ *common.Ctx, value string) (any, error) {
+	_, err := ParseTimeFormat(value)
+	return value, err
+}
+
+func normalizeTimezone(_ *common.Ctx, value string) (any, error) {
+	_, err := ParseTimezone(value)
+	return value, err
+}
+
+func normalizeLanguage(_ *common.Ctx, value string) (any, error) {
+	return common.ParseLanguage(value)
+}
+
+func normalizeOutput(_ *common.Ctx, value string) (any, error) {
+	for _, mode := range common.Modes {
+		if strings.EqualFold(mode, value) {
+			return mode, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown output mode [%s], expected one of %s", value, strings.Join(common.Modes, "|"))
+}
+
+func normalizeFormat(_ *common.Ctx, value string) (any, error) {
+	_, err := ParseTemplate(value)
+	return value, err
+}
+
+func normalizeMinutes(_ *common.Ctx, value string) (any, error) {
+	minutes, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid minutes [%s]", value)
+	}
+	if minutes > MaxOffsetMinutes || minutes < -MaxOffsetMinutes {
+		return nil, fmt.Errorf("invalid minutes [%s], expected between -%d and %d", value, MaxOffsetMinutes, MaxOffsetMinutes)
+	}
+	return minutes, nil
+}
+
+// ConfigFile is the TOML config file. Top level settings apply to every profile, the settings of
+// a [profiles.<name>] table override them when the profile is selected. Dotted names such as
+// offset.subuh are nested tables.
+type ConfigFile struct {
+	Settings map[string]any
+	Profiles map[string]map[string]any
+}
+
+// DefaultConfigPath is config.toml in the XDG config dir, e.g. ~/.config/<name>/config.toml
+func DefaultConfigPath(name string) string {
+	dir, _ := os.UserConfigDir()
+	return filepath.Join(dir, name, "config.toml")
+}
+
+var configCache struct {
+	sync.Mutex
+	path    string
+	modTime time.Time
+	file    *ConfigFile
+}
+
+// ReadConfigFile parses the config file, a missing file has no settings. The file is cached until
+// it is modified, so a running watch picks up changes.
+func ReadConfigFile(path string) (*ConfigFile, error) {
+	configCache.Lock()
+	defer configCache.Unlock()
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) || len(path) == 0 {
+		return &ConfigFile{Settings: map[string]any{}, Profiles: map[string]map[string]any{}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if configCache.file != nil && configCache.path == path && configCache.modTime.Equal(info.ModTime()) {
+		return configCache.file, nil
+	}
+	var data map[string]any
+	if _, err := toml.DecodeFile(path, &data); err != nil {
+		return nil, fmt.Errorf("unable to read config file %s: %w", path, err)
+	}
+	file := &ConfigFile{Settings: map[string]any{}, Profiles: map[string]map[string]any{}}
+	for name, value := range data {
+		if profiles, ok := value.(map[string]any); ok && name == configProfilesKey {
+			for profile, values := range profiles {
+				file.Profiles[profile] = map[string]any{}
+				if table, ok := values.(map[string]any); ok {
+					flattenConfig(file.Profiles[profile], "", table)
+				}
+			}
+			continue
+		}
+		flattenConfig(file.Settings, "", map[string]any{name: value})
+	}
+	configCache.path, configCache.modTime, configCache.file = path, info.ModTime(), file
+	return file, nil
+}
+
+// flattenConfig turns nested tables into dotted names, known settings are stored under their
+// canonical name so hand written keys may use any case
+func flattenConfig(dst map[string]any, prefix string, table map[string]any) {
+	for name, value := range table {
+		if len(prefix) != 0 {
+			name = prefix + "." + name
+		}
+		if nested, ok := value.(map[string]any); ok {
+			flattenConfig(dst, name, nested)
+			continue
+		}
+		if s, err := LookupSetting(name); err == nil {
+			name = s.Name
+		}
+		dst[name] = value
+	}
+}
+
+func nestConfig(values map[string]any) map[string]any {
+	res := map[string]any{}
+	for name, value := range values {
+		table := res
+		parts := strings.Split(name, ".")
+		for _, part := range parts[:len(parts)-1] {
+			nested, ok := table[part].(map[string]any)
+			if !ok {
+				nested = map[string]any{}
+				table[part] = nested
+			}
+			table = nested
+		}
+		table[parts[len(parts)-1]] = value
+	}
+	return res
+}
+
+// Write saves the file, comments of a hand written file are not kept
+func (f *ConfigFile) Write(path string) error {
+	defer func() {
+		configCache.Lock()
+		configCache.file = nil
+		configCache.Unlock()
+	}()
+	data := nestConfig(f.Settings)
+	if len(f.Profiles) != 0 {
+		profiles := map[string]any{}
+		for name, values := range f.Profiles {
+			profiles[name] = nestConfig(values)
+		}
+		data[configProfilesKey] = profiles
+	}
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(data); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+// ProfileNames lists the profiles of the file
+func (f *ConfigFile) ProfileNames() []string {
+	var res []string
+	for name := range f.Profiles {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// clone copies the settings and profiles, so a change isn't seen through the cache before it's
+// written
+func (f *ConfigFile) clone() *ConfigFile {
+	res := &ConfigFile{Settings: make(map[string]any, len(f.Settings)), Profiles: make(map[string]map[string]any, len(f.Profiles))}
+	for k, v := range f.Settings {
+		res.Settings[k] = v
+	}
+	for name, values := range f.Profiles {
+		res.Profiles[name] = make(map[string]any, len(values))
+		for k, v := range values {
+			res.Profiles[name][k] = v
+		}
+	}
+	return res
+}
+
+// scope returns the settings a profile changes, the top level ones when profile is empty, the
+// profile is added when missing
+func (f *ConfigFile) scope(profile string) map[string]any {
+	if len(profile) == 0 {
+		return f.Settings
+	}
+	if _, ok := f.Profiles[profile]; !ok {
+		f.Profiles[profile] = map[string]any{}
+	}
+	return f.Profiles[profile]
+}
+
+// LoadConfig checks the config file can be read and the selected profile exists
+func LoadConfig(ctx *common.Ctx) error {
+	file, err := ReadConfigFile(ctx.Config.ConfigPath)
+	if err != nil {
+		return err
+	}
+	profile := ctx.Config.Profile
+	if _, ok := file.Profiles[profile]; len(profile) != 0 && !ok {
+		if len(file.Profiles) == 0 {
+			return fmt.Errorf("profile [%s] not found in %s, create it with config set --profile %s", profile, ctx.Config.ConfigPath, profile)
+		}
+		return fmt.Errorf("profile [%s] not found in %s, expected one of %s", profile, ctx.Config.ConfigPath,
+			strings.Join(file.ProfileNames(), "|"))
+	}
+	return nil
+}
+
+// ConfigValue is the value a setting has and where it comes from
+type ConfigValue struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// lookupConfig resolves a setting, legacy holds the UserConfig values of the database by key
+func lookupConfig(file *ConfigFile, legacy map[string]string, profile string, s Setting) ConfigValue {
+	if value, ok := file.Profiles[profile][s.Name]; ok && len(profile) != 0 {
+		return ConfigValue{Name: s.Name, Value: fmt.Sprint(value), Source: "profile " + profile}
+	}
+	if value, ok := file.Settings[s.Name]; ok {
+		return ConfigValue{Name: s.Name, Value: fmt.Sprint(value), Source: ConfigSourceFile}
+	}
+	if value := legacy[s.Key]; len(s.Key) != 0 && len(value) != 0 {
+		return ConfigValue{Name: s.Name, Value: value, Source: ConfigSourceDatabase}
+	}
+	return ConfigValue{Name: s.Name, Value: s.Default, Source: ConfigSourceDefault}
+}
+
+func legacyConfig(ctx *common.Ctx, s Setting) map[string]string {
+	if len(s.Key) == 0 {
+		return nil
+	}
+	return map[string]string{s.Key: GetUserConfig(ctx, s.Key, "")}
+}
+
+// GetConfig returns the value of a setting in the selected profile, then the top level of the
+// config file, then the database and finally the default
+func GetConfig(ctx *common.Ctx, name string) string {
+	s, err := LookupSetting(name)
+	if err != nil {
+		return ""
+	}
+	file, err := ReadConfigFile(ctx.Config.ConfigPath)
+	if err != nil {
+		return s.Default
+	}
+	return lookupConfig(file, legacyConfig(ctx, s), ctx.Config.Profile, s).Value
+}
+
+// GetConfigValue is the value of a setting in a profile, with where it comes from
+func GetConfigValue(ctx *common.Ctx, profile string, name string) (ConfigValue, error) {
+	s, err := LookupSetting(name)
+	if err != nil {
+		return ConfigValue{}, err
+	}
+	file, err := ReadConfigFile(ctx.Config.ConfigPath)
+	if err != nil {
+		return ConfigValue{}, err
+	}
+	return lookupConfig(file, legacyConfig(ctx, s), profile, s), nil
+}
+
+// ListConfig lists every fixed setting and the offsets set in a profile, the top level or the
+// database
+func ListConfig(ctx *common.Ctx, profile string) ([]ConfigValue, error) {
+	file, err := ReadConfigFile(ctx.Config.ConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	legacy := map[string]string{}
+	for _, uc := range GetUserConfigs(ctx, "") {
+		legacy[uc.ID] = uc.Value
+	}
+	var res []ConfigValue
+	for _, s := range settings {
+		res = append(res, lookupConfig(file, legacy, profile, s))
+	}
+	offsets := map[string]bool{}
+	for _, values := range []map[string]any{file.Settings, file.Profiles[profile]} {
+		for name := range values {
+			if _, _, ok := parseOffsetSettingName(name); ok {
+				offsets[name] = true
+			}
+		}
+	}
+	for id := range legacy {
+		if zoneId, key, ok := parseOffsetConfigKey(id); ok {
+			offsets[offsetSettingName(zoneId, key)] = true
+		}
+	}
+	var names []string
+	for name := range offsets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if s, err := LookupSetting(name); err == nil {
+			res = append(res, lookupConfig(file, legacy, profile, s))
+		}
+	}
+	return res, nil
+}
+
+// SetConfig validates and stores a setting in a profile, at the top level when profile is empty,
+// and returns the stored value
+func SetConfig(ctx *common.Ctx, profile string, name string, value string) (ConfigValue, error) {
+	s, err := LookupSetting(name)
+	if err != nil {
+		return ConfigValue{}, err
+	}
+	file, err := ReadConfigFile(ctx.Config.ConfigPath)
+	if err != nil {
+		return ConfigValue{}, err
+	}
+	normalized, err := s.normalize(ctx, strings.TrimSpace(value))
+	if err != nil {
+		return ConfigValue{}, err
+	}
+	file = file.clone()
+	file.scope(profile)[s.Name] = normalized
+	if err = file.Write(ctx.Config.ConfigPath); err != nil {
+		return ConfigValue{}, err
+	}
+	return lookupConfig(file, nil, profile, s), nil
+}
+
+// UnsetConfig removes a setting from a profile, or from the top level and the database when
+// profile is empty
+func UnsetConfig(ctx *common.Ctx, profile string, name string) error {
+	s, err := LookupSetting(name)
+	if err != nil {
+		return err
+	}
+	file, err := ReadConfigFile(ctx.Config.ConfigPath)
+	if err != nil {
+		return err
+	}
+	if len(profile) == 0 && len(s.Key) != 0 {
+		DeleteUserConfig(ctx, s.Key)
+	}
+	values := file.Settings
+	if len(profile) != 0 {
+		values = file.Profiles[profile]
+	}
+	if _, ok := values[s.Name]; !ok {
+		return nil
+	}
+	file = file.clone()
+	delete(file.scope(profile), s.Name)
+	return file.Write(ctx.Config.ConfigPath)
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	ctx, _ := newTestCtx(t)
+	writeConfigFile(t, ctx.Config.ConfigPath, `
+Zone = "sgr01"
+time-format = "24h"
+custom = "kept"
+
+[offset]
+Subuh = 2
+
+[offset.wly01]
+maghrib = -1
+
+[profiles.work]
+zone = "JHR02"
+
+[profiles.work.offset]
+isyak = 3
+`)
+	file, err := ReadConfigFile(ctx.Config.ConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		ConfigZone:             "sgr01",
+		ConfigTimeFormat:       "24h",
+		"custom":               "kept",
+		"offset.subuh":         int64(2),
+		"offset.wly01.maghrib": int64(-1),
+	}
+	if len(file.Settings) != len(want) {
+		t.Errorf("got settings %v, want %v", file.Settings, want)
+	}
+	for name, value := range want {
+		if file.Settings[name] != value {
+			t.Errorf("%s: got %v (%T), want %v", name, file.Settings[name], file.Settings[name], value)
+		}
+	}
+	if names := file.ProfileNames(); strings.Join(names, ",") != "work" {
+		t.Fatalf("got profiles %v, want work", names)
+	}
+	if work := file.Profiles["work"]; len(work) != 2 || work[ConfigZone] != "JHR02" || work["offset.isyak"] != int64(3) {
+		t.Errorf("got work profile %v", work)
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.toml")
+	writeConfigFile(t, invalid, "zone = [")
+	if _, err = ReadConfigFile(invalid); err == nil {
+		t.Error("invalid TOML read without an error")
+	}
+}
+
+func TestGetConfigPrecedence(t *testing.T) {
+	ctx, _ := newTestCtx(t)
+	check := func(profile string, value string, source string) {
+		t.Helper()
+		got, err := GetConfigValue(ctx, profile, ConfigZone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Value != value || got.Source != source {
+			t.Errorf("profile %q: got %s from %s, want %s from %s", profile, got.Value, got.Source, value, source)
+		}
+		ctx.Config.Profile = profile
+		if got := GetConfig(ctx, ConfigZone); got != value {
+			t.Errorf("profile %q: GetConfig got %s, want %s", profile, got, value)
+		}
+		ctx.Config.Profile = ""
+	}
+
+	check("", "WLY01", ConfigSourceDefault)
+	SetUserConfig(ctx, "ZONE_ID", "KTN01")
+	check("", "KTN01", ConfigSourceDatabase)
+	writeConfigFile(t, ctx.Config.ConfigPath, `
+zone = "SGR01"
+
+[profiles.work]
+zone = "JHR02"
+
+[profiles.home]
+lang = "en"
+`)
+	check("", "SGR01", ConfigSourceFile)
+	check("work", "JHR02", "profile work")
+	check("home", "SGR01", ConfigSourceFile)
+}
+
+func TestSetAndUnsetConfig(t *testing.T) {
+	ctx, _ := newTestCtx(t)
+	GetZoneStates(ctx)
+
+	value, err := SetConfig(ctx, "", ConfigZone, " sgr01 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value.Value != "SGR01" || value.Source != ConfigSourceFile {
+		t.Errorf("got %+v, want SGR01 from the file", value)
+	}
+	if _, err = SetConfig(ctx, "work", ConfigZone, "jhr02"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = SetConfig(ctx, "work", "Offset.WLY01.Maghrib", "-2"); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct{ name, value string }{
+		{ConfigZone, "xxx99"},
+		{ConfigTimeFormat, "{{"},
+		{ConfigOutput, "yaml"},
+		{"offset.subuh", "soon"},
+		{"offset.dhuha", "1"},
+	} {
+		if _, err := SetConfig(ctx, "", tt.name, tt.value); err == nil {
+			t.Errorf("%s = %q stored without an error", tt.name, tt.value)
+		}
+	}
+
+	content, err := os.ReadFile(ctx.Config.ConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`zone = "SGR01"`, "[profiles.work]", `zone = "JHR02"`, "[profiles.work.offset.wly01]", "maghrib = -2"} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("missing %s in\n%s", want, content)
+		}
+	}
+	file, err := ReadConfigFile(ctx.Config.ConfigPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Profiles["work"]["offset.wly01.maghrib"] != int64(-2) {
+		t.Errorf("got work profile %v", file.Profiles["work"])
+	}
+
+	// Unsetting the top level also removes the value stored in the database
+	SetUserConfig(ctx, "ZONE_ID", "KTN01")
+	if err = UnsetConfig(ctx, "", ConfigZone); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := GetConfigValue(ctx, "", ConfigZone); value.Value != "WLY01" || value.Source != ConfigSourceDefault {
+		t.Errorf("after unset got %+v, want the default", value)
+	}
+	if value, _ := GetConfigValue(ctx, "work", ConfigZone); value.Value != "JHR02" {
+		t.Errorf("after unsetting the top level, work got %+v, want JHR02", value)
+	}
+	if err = UnsetConfig(ctx, "work", ConfigZone); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := GetConfigValue(ctx, "work", ConfigZone); value.Source != ConfigSourceDefault {
+		t.Errorf("after unsetting work got %+v, want the default", value)
+	}
+	if err = UnsetConfig(ctx, "", ConfigTimeFormat); err != nil {
+		t.Errorf("unsetting a missing setting: %s", err)
+	}
+	if err = UnsetConfig(ctx, "", "colour"); err == nil {
+		t.Error("unknown setting unset without an error")
+	}
+}
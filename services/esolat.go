@@ -0,0 +1,98 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/gocolly/colly"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"strings"
+	"time"
+)
+
+const (
+	URL         = "https://www.e-solat.gov.my/index.php?r=esolatApi/takwimsolat&period=year&zone=%s"
+	DurationURL = "https://www.e-solat.gov.my/index.php?r=esolatApi/takwimsolat&period=duration&zone=%s"
+	ZonesURL    = "https://www.e-solat.gov.my/index.php?siteId=24&pageId=24"
+)
+
+func init() {
+	RegisterProvider(DefaultProvider, func(ctx *common.Ctx) Provider {
+		return &ESolatProvider{}
+	})
+}
+
+// ESolatProvider scrapes JAKIM's e-solat.gov.my
+type ESolatProvider struct{}
+
+func (e *ESolatProvider) Name() string {
+	return DefaultProvider
+}
+
+func (e *ESolatProvider) Zones() ([]State, error) {
+	var states []State
+	c := colly.NewCollector()
+
+	c.OnHTML("select#inputZone:first-child", func(p *colly.HTMLElement) {
+		p.ForEach("optgroup", func(_ int, eState *colly.HTMLElement) {
+			state := &State{
+				Name: eState.Attr("label"),
+			}
+			eState.ForEach("option", func(i int, eZone *colly.HTMLElement) {
+				id := eZone.Attr("value")
+				if i == 0 {
+					state.ID = id[:3]
+				}
+				zone := &Zone{
+					ID:        id,
+					Locations: processLocationName(eZone.Text),
+					State:     state,
+				}
+				state.Zones = append(state.Zones, *zone)
+				//zones = append(zones, *zone)
+			})
+			states = append(states, *state)
+		})
+	})
+	if err := c.Visit(ZonesURL); err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+func (e *ESolatProvider) PrayerTimes(zoneId string, from time.Time, to time.Time) ([]PrayerDate, error) {
+	c := colly.NewCollector()
+	var resDto = &PrayerTimesDto{}
+	var fetchErr error
+	c.OnResponse(func(r *colly.Response) {
+		if err := json.Unmarshal(r.Body, resDto); err != nil {
+			fetchErr = err
+			return
+		}
+		for i := range resDto.PrayerTimes {
+			resDto.PrayerTimes[i].ZoneID = zoneId
+		}
+	})
+	var err error
+	zoneId = strings.ToUpper(zoneId)
+	if isWholeYear(from, to) && from.Year() == Today().Year() {
+		err = c.Visit(fmt.Sprintf(URL, zoneId))
+	} else {
+		// period=year only covers the current year, anything else is requested as a duration
+		err = c.Post(fmt.Sprintf(DurationURL, zoneId), map[string]string{
+			"datestart": from.Format(InputDateLayout),
+			"dateend":   to.Format(InputDateLayout),
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resDto.PrayerTimes, fetchErr
+}
+
+func isWholeYear(from time.Time, to time.Time) bool {
+	return from.Year() == to.Year() && from.YearDay() == 1 && to.Month() == time.December && to.Day() == 31
+}
+
+func processLocationName(locations string) string {
+	return strings.ReplaceAll(locations[8:], " dan ", ", ")
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"golang.org/x/sync/singleflight"
+	"gorm.io/gorm"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// RefreshInterval is how long fetched prayer times are trusted before being re-fetched,
+	// JAKIM occasionally revises times already published
+	RefreshInterval = 30 * 24 * time.Hour
+	// RetryInterval is the minimum wait between two fetch attempts for the same zone and year
+	RetryInterval = 24 * time.Hour
+	// PrefetchWindow is how long before the end of the year the next year is fetched
+	PrefetchWindow = 31 * 24 * time.Hour
+)
+
+// FetchRecord tracks which years of prayer times are stored for a zone
+type FetchRecord struct {
+	ID        string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ZoneID    string `gorm:"index"`
+	Year      int
+	// Count is the number of days received on the last successful fetch
+	Count int
+	// Checksum of the last received data, used to detect revised times
+	Checksum string
+	// FetchedAt is the last time data was received
+	FetchedAt time.Time
+	// CheckedAt is the last fetch attempt, successful or not
+	CheckedAt time.Time
+	// Provider that supplied the stored times
+	Provider string
+	// Unreachable is set when the last attempt failed because the provider couldn't be reached
+	Unreachable bool
+}
+
+func fetchRecordId(zoneId string, year int) string {
+	return fmt.Sprintf("%s-%d", zoneId, year)
+}
+
+func getFetchRecord(db *gorm.DB, zoneId string, year int) *FetchRecord {
+	record := &FetchRecord{}
+	if db.First(record, "id = ?", fetchRecordId(zoneId, year)).Error != nil {
+		return nil
+	}
+	return record
+}
+
+// isFetchDue reports whether a zone's year should be (re-)fetched from provider
+func isFetchDue(record *FetchRecord, provider Provider, now time.Time) bool {
+	if record == nil {
+		return true
+	}
+	if now.Sub(record.CheckedAt) < RetryInterval {
+		return false
+	}
+	return record.Count == 0 || record.Provider != provider.Name() || now.Sub(record.FetchedAt) >= RefreshInterval
+}
+
+// isPrefetchDue reports whether next year's times should be fetched ahead of time
+func isPrefetchDue(now time.Time) bool {
+	now = now.In(JakimLocation)
+	nextYear := time.Date(now.Year()+1, time.January, 1, 0, 0, 0, 0, JakimLocation)
+	return nextYear.Sub(now) <= PrefetchWindow
+}
+
+// refreshes merges concurrent refreshes of the same zone and year, e.g. from parallel serve requests
+var refreshes singleflight.Group
+
+type refreshResult struct {
+	record  *FetchRecord
+	changed bool
+}
+
+// refreshYear fetches a zone's prayer times for year, stores them and records the attempt.
+// It reports whether the stored times changed.
+func refreshYear(db *gorm.DB, provider Provider, zoneId string, zone *Zone, year int) (*FetchRecord, bool, error) {
+	key := fmt.Sprintf("%s/%s", provider.Name(), fetchRecordId(zoneId, year))
+	v, err, _ := refreshes.Do(key, func() (interface{}, error) {
+		record, changed, err := fetchYear(db, provider, zoneId, zone, year)
+		return refreshResult{record: record, changed: changed}, err
+	})
+	res := v.(refreshResult)
+	return res.record, res.changed, err
+}
+
+func fetchYear(db *gorm.DB, provider Provider, zoneId string, zone *Zone, year int) (*FetchRecord, bool, error) {
+	record := getFetchRecord(db, zoneId, year)
+	if record == nil {
+		record = &FetchRecord{ID: fetchRecordId(zoneId, year), ZoneID: zoneId, Year: year}
+	}
+	now := time.Now()
+	record.CheckedAt = now
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, JakimLocation)
+	to := time.Date(year, time.December, 31, 0, 0, 0, 0, JakimLocation)
+	prayerTimes, err := provider.PrayerTimes(zoneId, from, to)
+	recordFetch("times", provider.Name(), err)
+	record.Unreachable = err != nil && isNetworkError(err)
+	if err != nil {
+		log.Printf("Unable to fetch prayer times for %s (%d) from %s: %s", zoneId, year, provider.Name(), err)
+	}
+	changed := false
+	if err == nil && len(prayerTimes) != 0 {
+		for i := range prayerTimes {
+			t := &prayerTimes[i]
+			if date, err := time.ParseInLocation(PrimaryDateLayout, t.Date, JakimLocation); err == nil {
+				t.ID = prayerDateId(date, zoneId)
+			} else {
+				t.ID = fmt.Sprintf("%s-%s", strings.ReplaceAll(t.Date, "/", ""), zoneId)
+			}
+			t.ZoneID = zoneId
+			t.Zone = zone
+		}
+		checksum := prayerTimesChecksum(prayerTimes)
+		changed = checksum != record.Checksum
+		if changed && len(record.Checksum) != 0 {
+			log.Printf("Prayer times for %s (%d) have been revised", zoneId, year)
+		}
+		updatePrayerTime(&prayerTimes, db)
+		record.Count = len(prayerTimes)
+		record.Checksum = checksum
+		record.FetchedAt = now
+		record.Provider = provider.Name()
+	}
+	db.Save(record)
+	return record, changed, err
+}
+
+// isNetworkError reports whether err comes from being unable to reach the provider, rather than
+// from the provider answering without the requested times
+func isNetworkError(err error) bool {
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr)
+}
+
+func prayerTimesChecksum(prayerTimes []PrayerDate) string {
+	h := sha1.New()
+	for _, p := range prayerTimes {
+		_, _ = fmt.Fprintf(h, "%s|%s|%s|%s|%s|%s|%s|%s|%s\n",
+			p.Date, p.Hijri, p.Imsak, p.Subuh, p.Syuruk, p.Zohor, p.Asar, p.Maghrib, p.Isyak)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// UpdateResult describes the outcome of refreshing a zone's year
+type UpdateResult struct {
+	ZoneID  string
+	Year    int
+	Count   int
+	Changed bool
+	Skipped bool
+	Err     error
+}
+
+// UpdatePrayerTimes refreshes the current year, and the next one when it is due, for each zone.
+// Years fetched recently are skipped unless force is set.
+func UpdatePrayerTimes(ctx *common.Ctx, zoneIds []string, force bool) []UpdateResult {
+	db, err := OpenDb(ctx)
+	if err != nil {
+		return []UpdateResult{{Err: err}}
+	}
+	provider, err := GetProvider(ctx)
+	if err != nil {
+		return []UpdateResult{{Err: err}}
+	}
+	now := time.Now().In(JakimLocation)
+	years := []int{now.Year()}
+	if isPrefetchDue(now) {
+		years = append(years, now.Year()+1)
+	}
+	var results []UpdateResult
+	for _, zoneId := range zoneIds {
+		zoneId = strings.ToUpper(zoneId)
+		zone := getZone(ctx, zoneId)
+		if zone == nil {
+			results = append(results, UpdateResult{ZoneID: zoneId, Err: fmt.Errorf("zone with id [%s] not found", zoneId)})
+			continue
+		}
+		for _, year := range years {
+			res := UpdateResult{ZoneID: zoneId, Year: year}
+			record := getFetchRecord(db, zoneId, year)
+			if !force && !isFetchDue(record, provider, now) {
+				res.Skipped = true
+				res.Count = record.Count
+			} else {
+				record, res.Changed, res.Err = refreshYear(db, provider, zoneId, zone, year)
+				res.Count = record.Count
+			}
+			results = append(results, res)
+		}
+	}
+	return results
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestIsFetchDue(t *testing.T) {
+	now := time.Date(2024, time.March, 12, 10, 0, 0, 0, JakimLocation)
+	provider := &fakeProvider{name: "fake"}
+	fetched := func(checked time.Duration, fetched time.Duration) *FetchRecord {
+		return &FetchRecord{Count: 366, Provider: "fake", CheckedAt: now.Add(-checked), FetchedAt: now.Add(-fetched)}
+	}
+	tests := []struct {
+		name   string
+		record *FetchRecord
+		want   bool
+	}{
+		{"never fetched", nil, true},
+		{"fresh", fetched(time.Hour, time.Hour), false},
+		{"checked within the retry interval", fetched(RetryInterval-time.Minute, RefreshInterval), false},
+		{"older than the refresh interval", fetched(RetryInterval, RefreshInterval), true},
+		{"checked recently but fetched long ago", fetched(RetryInterval, RefreshInterval-time.Minute), false},
+		{"failed within the retry interval", &FetchRecord{CheckedAt: now.Add(-time.Hour)}, false},
+		{"failed before the retry interval", &FetchRecord{CheckedAt: now.Add(-RetryInterval)}, true},
+		{"other provider", &FetchRecord{Count: 366, Provider: "other", CheckedAt: now.Add(-RetryInterval), FetchedAt: now.Add(-RetryInterval)}, true},
+		{"other provider within the retry interval", &FetchRecord{Count: 366, Provider: "other", CheckedAt: now.Add(-time.Hour), FetchedAt: now.Add(-time.Hour)}, false},
+	}
+	for _, tt := range tests {
+		if got := isFetchDue(tt.record, provider, now); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsPrefetchDue(t *testing.T) {
+	tests := []struct {
+		now  time.Time
+		want bool
+	}{
+		{time.Date(2024, time.March, 12, 10, 0, 0, 0, JakimLocation), false},
+		{time.Date(2024, time.November, 30, 23, 59, 0, 0, JakimLocation), false},
+		{time.Date(2024, time.December, 1, 0, 0, 0, 0, JakimLocation), true},
+		{time.Date(2024, time.December, 31, 23, 59, 0, 0, JakimLocation), true},
+		{time.Date(2025, time.January, 1, 0, 0, 0, 0, JakimLocation), false},
+		// Dates are in Malaysian time, it is already December there
+		{time.Date(2024, time.November, 30, 16, 0, 0, 0, time.UTC), true},
+		{time.Date(2024, time.November, 30, 15, 59, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		if got := isPrefetchDue(tt.now); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestFetchYear(t *testing.T) {
+	ctx, provider := newTestCtx(t)
+	db, err := OpenDb(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zone := &Zone{ID: "WLY01"}
+
+	// First fetch
+	record, changed, err := fetchYear(db, provider, "WLY01", zone, 2024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || record.Count != 366 || record.Provider != provider.name || record.Unreachable || len(record.Checksum) == 0 {
+		t.Fatalf("first fetch: got changed %v, record %+v", changed, record)
+	}
+	if isFetchDue(getFetchRecord(db, "WLY01", 2024), provider, time.Now()) {
+		t.Error("first fetch: fetch due again right away")
+	}
+	first := *record
+
+	// Failed retry keeps the stored times
+	provider.err = &url.Error{Op: "Post", URL: "https://www.e-solat.gov.my", Err: errors.New("no such host")}
+	record, changed, err = fetchYear(db, provider, "WLY01", zone, 2024)
+	if err == nil || changed || !record.Unreachable {
+		t.Errorf("retry: got changed %v, err %v, record %+v", changed, err, record)
+	}
+	if record.Count != first.Count || record.Checksum != first.Checksum || !record.FetchedAt.Equal(first.FetchedAt) {
+		t.Errorf("retry: got record %+v, want the stored times kept from %+v", record, first)
+	}
+	if isFetchDue(getFetchRecord(db, "WLY01", 2024), provider, time.Now().Add(RetryInterval-time.Minute)) {
+		t.Error("retry: fetch due within the retry interval")
+	}
+	provider.err = nil
+
+	// Provider change refetches the same times
+	other := &fakeProvider{name: provider.name + "-other", calls: map[int]int{}}
+	if !isFetchDue(getFetchRecord(db, "WLY01", 2024), other, time.Now().Add(RetryInterval)) {
+		t.Error("provider change: fetch not due after the retry interval")
+	}
+	record, changed, err = fetchYear(db, other, "WLY01", zone, 2024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || record.Provider != other.name || record.Unreachable {
+		t.Errorf("provider change: got changed %v, record %+v", changed, record)
+	}
+
+	// Revised times change the checksum and the stored times
+	other.times = map[string]string{"maghrib": "19:22:00"}
+	record, changed, err = fetchYear(db, other, "WLY01", zone, 2024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || record.Checksum == first.Checksum {
+		t.Errorf("revision: got changed %v, checksum %s", changed, record.Checksum)
+	}
+	stored := &PrayerDate{}
+	if err = db.First(stored, "id = ?", prayerDateId(date(t, "2024-03-12"), "WLY01")).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.Maghrib != "19:22" {
+		t.Errorf("revision: stored maghrib %s, want 19:22", stored.Maghrib)
+	}
+	if n := provider.fetches(2024) + other.fetches(2024); n != 4 {
+		t.Errorf("%d fetches, want 4", n)
+	}
+}
@@ -0,0 +1,244 @@
+package services
+
+import (
+	"fmt"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hijriFormat is how e-solat publishes Hijri dates, YYYY-MM-DD
+const hijriFormat = "%04d-%02d-%02d"
+
+const (
+	HijriSourceJakim   = "jakim"
+	HijriSourceTabular = "tabular"
+)
+
+// hijriMonths are the JAKIM month names, translated with common.T
+var hijriMonths = []string{
+	"Muharram", "Safar", "Rabiulawal", "Rabiulakhir", "Jamadilawal", "Jamadilakhir",
+	"Rejab", "Syaaban", "Ramadan", "Syawal", "Zulkaedah", "Zulhijjah",
+}
+
+// HijriDate is a day of the Islamic calendar, months are 1 based
+type HijriDate struct {
+	Year  int `json:"year"`
+	Month int `json:"month"`
+	Day   int `json:"day"`
+}
+
+// HijriMonthName is the name of a month in the current language
+func HijriMonthName(month int) string {
+	if month < 1 || month > len(hijriMonths) {
+		return strconv.Itoa(month)
+	}
+	return common.T(hijriMonths[month-1])
+}
+
+// ParseHijri parses YYYY-MM-DD as published by e-solat, or a day and month name with an optional year
+// such as "1 Ramadan 1447". The year is 0 when omitted.
+func ParseHijri(value string) (HijriDate, error) {
+	var h HijriDate
+	value = strings.TrimSpace(value)
+	if _, err := fmt.Sscanf(value, "%d-%d-%d", &h.Year, &h.Month, &h.Day); err == nil {
+		return h, h.validate()
+	}
+	h = HijriDate{}
+	fields := strings.Fields(value)
+	if len(fields) < 2 {
+		return h, fmt.Errorf("invalid hijri date [%s], expected YYYY-MM-DD or a day and month such as 1 Ramadan 1447", value)
+	}
+	day, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return h, fmt.Errorf("invalid hijri day [%s]", fields[0])
+	}
+	h.Day = day
+	monthFields := fields[1:]
+	if year, err := strconv.Atoi(fields[len(fields)-1]); err == nil && len(fields) > 2 {
+		h.Year = year
+		monthFields = fields[1 : len(fields)-1]
+	}
+	if h.Month = parseHijriMonth(strings.Join(monthFields, " ")); h.Month == 0 {
+		return h, fmt.Errorf("unknown hijri month [%s]", strings.Join(monthFields, " "))
+	}
+	return h, h.validate()
+}
+
+func parseHijriMonth(value string) int {
+	if month, err := strconv.Atoi(value); err == nil {
+		return month
+	}
+	value = normalizeSearch(value)
+	for i, name := range hijriMonths {
+		for _, translated := range common.Translations(name) {
+			if normalizeSearch(translated) == value {
+				return i + 1
+			}
+		}
+	}
+	return 0
+}
+
+func (h HijriDate) validate() error {
+	if h.Month < 1 || h.Month > 12 || h.Day < 1 || h.Day > 30 || h.Year < 0 {
+		return fmt.Errorf("invalid hijri date [%s]", h)
+	}
+	return nil
+}
+
+func (h HijriDate) IsZero() bool {
+	return h == HijriDate{}
+}
+
+// Compare returns -1, 0 or 1 when h is before, the same as or after o
+func (h HijriDate) Compare(o HijriDate) int {
+	for _, d := range [][2]int{{h.Year, o.Year}, {h.Month, o.Month}, {h.Day, o.Day}} {
+		if d[0] < d[1] {
+			return -1
+		} else if d[0] > d[1] {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (h HijriDate) Before(o HijriDate) bool {
+	return h.Compare(o) < 0
+}
+
+func (h HijriDate) After(o HijriDate) bool {
+	return h.Compare(o) > 0
+}
+
+// String formats the date the way e-solat publishes it
+func (h HijriDate) String() string {
+	return fmt.Sprintf(hijriFormat, h.Year, h.Month, h.Day)
+}
+
+// Format describes the date with the month name in the current language, e.g. 1 Ramadan 1447
+func (h HijriDate) Format() string {
+	if h.Year == 0 {
+		return fmt.Sprintf("%d %s", h.Day, HijriMonthName(h.Month))
+	}
+	return fmt.Sprintf("%d %s %d", h.Day, HijriMonthName(h.Month), h.Year)
+}
+
+// HijriDate parses the Hijri date published with the prayer times
+func (p *PrayerDate) HijriDate() (HijriDate, error) {
+	return ParseHijri(p.Hijri)
+}
+
+// HijriDisplay is the Hijri date with the month name, or as stored when it can't be parsed
+func (p *PrayerDate) HijriDisplay() string {
+	if h, err := p.HijriDate(); err == nil {
+		return h.Format()
+	}
+	return p.Hijri
+}
+
+// Tabular (arithmetic) Islamic calendar, it can be a day or two off the dates JAKIM announces from
+// moon sighting, so it is only used when no published date is cached
+const hijriEpoch = 1948440 // julian day number of 1 Muharram 1
+
+func tabularHijriToJDN(h HijriDate) int {
+	return h.Day + int(math.Ceil(29.5*float64(h.Month-1))) + (h.Year-1)*354 + (3+11*h.Year)/30 + hijriEpoch - 1
+}
+
+func julianDayNumber(date time.Time) int {
+	day := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	return int(day.Unix()/(24*60*60)) + 2440588
+}
+
+// TabularHijri converts a date to the tabular Islamic calendar
+func TabularHijri(date time.Time) HijriDate {
+	jdn := julianDayNumber(date)
+	year := (30*(jdn-hijriEpoch) + 10646) / 10631
+	month := int(math.Ceil(float64(jdn-29-tabularHijriToJDN(HijriDate{year, 1, 1}))/29.5)) + 1
+	if month > 12 {
+		month = 12
+	}
+	day := jdn - tabularHijriToJDN(HijriDate{year, month, 1}) + 1
+	return HijriDate{Year: year, Month: month, Day: day}
+}
+
+// TabularGregorian converts a tabular Islamic calendar date to midnight of the day in Malaysia
+func (h HijriDate) TabularGregorian() time.Time {
+	days := tabularHijriToJDN(h) - 2440588
+	t := time.Unix(int64(days)*24*60*60, 0).UTC()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, JakimLocation)
+}
+
+// HijriConversion is a Gregorian date and its Hijri date
+type HijriConversion struct {
+	Date   time.Time
+	Hijri  HijriDate
+	Source string
+}
+
+type HijriConversionResponse struct {
+	Date      string    `json:"date"`
+	Hijri     string    `json:"hijri"`
+	Formatted string    `json:"formatted"`
+	Parts     HijriDate `json:"parts"`
+	Source    string    `json:"source"`
+}
+
+func (c *HijriConversion) ToJSONResponse() HijriConversionResponse {
+	return HijriConversionResponse{
+		Date:      c.Date.Format(InputDateLayout),
+		Hijri:     c.Hijri.String(),
+		Formatted: c.Hijri.Format(),
+		Parts:     c.Hijri,
+		Source:    c.Source,
+	}
+}
+
+// hijriSource tells whether the Hijri date of a row was published by JAKIM or calculated offline
+func hijriSource(p *PrayerDate) string {
+	if p.Calculated {
+		return HijriSourceTabular
+	}
+	return HijriSourceJakim
+}
+
+// ToHijri converts a date using the Hijri dates JAKIM published with the zone's prayer times,
+// falling back to the tabular calendar
+func ToHijri(ctx *common.Ctx, zoneId string, date time.Time) HijriConversion {
+	for _, p := range GetPrayerTimes(ctx, zoneId, date, date) {
+		if h, err := p.HijriDate(); err == nil && p.Date == date.Format(PrimaryDateLayout) {
+			return HijriConversion{Date: date, Hijri: h, Source: hijriSource(&p)}
+		}
+	}
+	return HijriConversion{Date: date, Hijri: TabularHijri(date), Source: HijriSourceTabular}
+}
+
+// FromHijri finds the date of a Hijri day, looking around the tabular estimate for the date JAKIM
+// published and falling back to the estimate
+func FromHijri(ctx *common.Ctx, zoneId string, h HijriDate) HijriConversion {
+	estimate := h.TabularGregorian()
+	for _, p := range GetPrayerTimes(ctx, zoneId, estimate.AddDate(0, 0, -3), estimate.AddDate(0, 0, 3)) {
+		if published, err := p.HijriDate(); err == nil && published == h {
+			if date, err := time.ParseInLocation(PrimaryDateLayout, p.Date, JakimLocation); err == nil {
+				return HijriConversion{Date: date, Hijri: h, Source: hijriSource(&p)}
+			}
+		}
+	}
+	return HijriConversion{Date: estimate, Hijri: h, Source: HijriSourceTabular}
+}
+
+// InGregorianYear fills in a missing Hijri year with the first one where the day falls in the given
+// Gregorian year, so "1 Ramadan" means the one of the current year
+func (h HijriDate) InGregorianYear(year int) HijriDate {
+	if h.Year != 0 {
+		return h
+	}
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, JakimLocation)
+	h.Year = TabularHijri(start).Year
+	if h.TabularGregorian().Before(start) {
+		h.Year++
+	}
+	return h
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"testing"
+)
+
+func TestTabularHijriNearJakimAnnouncements(t *testing.T) {
+	// First days of the month announced by JAKIM from moon sighting, the tabular calendar may be a
+	// day off
+	tests := []struct {
+		date  string
+		hijri HijriDate
+	}{
+		{"2024-03-12", HijriDate{1445, 9, 1}},
+		{"2024-04-10", HijriDate{1445, 10, 1}},
+		{"2025-03-02", HijriDate{1446, 9, 1}},
+		{"2025-03-31", HijriDate{1446, 10, 1}},
+	}
+	for _, tt := range tests {
+		day := date(t, tt.date)
+		if got := TabularHijri(day); got != tt.hijri && TabularHijri(day.AddDate(0, 0, -1)) != tt.hijri && TabularHijri(day.AddDate(0, 0, 1)) != tt.hijri {
+			t.Errorf("TabularHijri(%s) = %s, more than a day from %s", tt.date, got, tt.hijri)
+		}
+	}
+}
+
+func TestTabularHijriRoundTrip(t *testing.T) {
+	start := date(t, "2024-01-01")
+	for i := 0; i < 3*366; i++ {
+		day := start.AddDate(0, 0, i)
+		h := TabularHijri(day)
+		if got := h.TabularGregorian(); !got.Equal(day) {
+			t.Fatalf("%s -> %s -> %s", day.Format(InputDateLayout), h, got.Format(InputDateLayout))
+		}
+	}
+}
+
+func TestParseHijri(t *testing.T) {
+	tests := []struct {
+		value string
+		want  HijriDate
+		err   bool
+	}{
+		{"1445-09-01", HijriDate{1445, 9, 1}, false},
+		{"1 Ramadan 1447", HijriDate{1447, 9, 1}, false},
+		{"1 ramadan", HijriDate{0, 9, 1}, false},
+		{"10 Zulhijjah", HijriDate{0, 12, 10}, false},
+		{"12 Rabi al-Awwal 1446", HijriDate{1446, 3, 12}, false},
+		{"1 9 1446", HijriDate{1446, 9, 1}, false},
+		{"1445-13-01", HijriDate{}, true},
+		{"31 Ramadan", HijriDate{}, true},
+		{"1 Januari", HijriDate{}, true},
+		{"Ramadan", HijriDate{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseHijri(tt.value)
+		if (err != nil) != tt.err {
+			t.Errorf("ParseHijri(%q) error %v, want error %v", tt.value, err, tt.err)
+			continue
+		}
+		if !tt.err && got != tt.want {
+			t.Errorf("ParseHijri(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestHijriInGregorianYear(t *testing.T) {
+	h := HijriDate{Month: 9, Day: 1}.InGregorianYear(2025)
+	if h != (HijriDate{1446, 9, 1}) {
+		t.Errorf("1 Ramadan in 2025 is %s, want 1446-09-01", h)
+	}
+}
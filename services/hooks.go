@@ -0,0 +1,190 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// AllPrayers matches every prayer in Hook.Prayer
+	AllPrayers         = "*"
+	DefaultHookTimeout = time.Minute
+)
+
+// Hook is a shell command run when a prayer time, or an offset from it, is reached
+type Hook struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	// Prayer key the hook runs for, or AllPrayers
+	Prayer string
+	// Offset in minutes before the prayer, negative values run after it
+	Offset  int
+	Command string
+	Timeout time.Duration
+}
+
+func (h *Hook) matches(ev Event) bool {
+	return (h.Prayer == AllPrayers || strings.EqualFold(h.Prayer, ev.Prayer.Key)) &&
+		time.Duration(h.Offset)*time.Minute == ev.Offset
+}
+
+// Env is the environment passed to the hook's command for ev
+func (h *Hook) Env(ev Event) []string {
+	env := map[string]string{
+		"EVENT":        ev.Type,
+		"PRAYER":       ev.Prayer.Key,
+		"ZONE":         ev.ZoneID,
+		"TIME":         ev.Prayer.Time.Format(time.RFC3339),
+		"TIMESTAMP":    strconv.FormatInt(ev.Prayer.Time.Unix(), 10),
+		"DISPLAY_TIME": ev.Prayer.DisplayValue,
+		"PRAYER_NAME":  ev.Prayer.Name(),
+		"ADJUSTMENT":   strconv.Itoa(int(ev.Prayer.Offset / time.Minute)),
+		"OFFSET":       strconv.Itoa(int(ev.Offset / time.Minute)),
+		"HOOK_ID":      strconv.Itoa(int(h.ID)),
+	}
+	if ev.Zone != nil {
+		env["LOCATIONS"] = ev.Zone.Locations
+	}
+	var res []string
+	for k, v := range env {
+		res = append(res, fmt.Sprintf("%s%s=%s", common.ENV_PREFIX, k, v))
+	}
+	return res
+}
+
+// Run executes the hook's command for ev, killing it after its timeout
+func (h *Hook) Run(ev Event) error {
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+	cmd := shellCommand(h.Command)
+	cmd.Env = append(os.Environ(), h.Env(ev)...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	setProcessGroup(cmd)
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	var err error
+	timer := time.NewTimer(timeout)
+	select {
+	case err = <-done:
+		timer.Stop()
+	case <-timer.C:
+		// Kill the whole group, children of the shell would otherwise keep the output open
+		killProcessGroup(cmd)
+		<-done
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	if output := strings.TrimSpace(out.String()); len(output) != 0 {
+		log.Printf("Hook #%d output: %s", h.ID, output)
+	}
+	log.Printf("Hook #%d finished in %s", h.ID, time.Since(start).Round(time.Millisecond))
+	return err
+}
+
+func GetHooks(ctx *common.Ctx) []Hook {
+	db, err := OpenDb(ctx)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	var hooks []Hook
+	db.Order("id").Find(&hooks)
+	return hooks
+}
+
+func GetHook(ctx *common.Ctx, id uint) *Hook {
+	db, err := OpenDb(ctx)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	hook := &Hook{}
+	if db.First(hook, id).Error != nil {
+		return nil
+	}
+	return hook
+}
+
+// AddHook stores a hook, its prayer is normalized to a prayer key and defaults to AllPrayers
+func AddHook(ctx *common.Ctx, hook *Hook) error {
+	if len(hook.Prayer) == 0 {
+		hook.Prayer = AllPrayers
+	}
+	if hook.Prayer != AllPrayers {
+		key, err := NormalizePrayerKey(hook.Prayer)
+		if err != nil {
+			return err
+		}
+		hook.Prayer = key
+	}
+	db, err := OpenDb(ctx)
+	if err != nil {
+		return err
+	}
+	return db.Create(hook).Error
+}
+
+func RemoveHook(ctx *common.Ctx, id uint) error {
+	db, err := OpenDb(ctx)
+	if err != nil {
+		return err
+	}
+	tx := db.Delete(&Hook{}, id)
+	if tx.Error == nil && tx.RowsAffected == 0 {
+		return fmt.Errorf("hook #%d not found", id)
+	}
+	return tx.Error
+}
+
+// HookNotifier runs the stored hooks matching each event
+type HookNotifier struct {
+	Ctx *common.Ctx
+	// DryRun logs the hooks that would run without running them
+	DryRun bool
+}
+
+// Offsets subscribes to the offsets of every stored hook, hooks are re-read so they can change while running
+func (n *HookNotifier) Offsets() []time.Duration {
+	var offsets []time.Duration
+	for _, h := range GetHooks(n.Ctx) {
+		if h.Offset != 0 {
+			offsets = append(offsets, time.Duration(h.Offset)*time.Minute)
+		}
+	}
+	return offsets
+}
+
+func (n *HookNotifier) Notify(ev Event) error {
+	for _, h := range GetHooks(n.Ctx) {
+		if !h.matches(ev) {
+			continue
+		}
+		if n.DryRun {
+			log.Printf("Dry run, hook #%d for %s: %s", h.ID, ev.Summary(), h.Command)
+			continue
+		}
+		log.Printf("Running hook #%d for %s: %s", h.ID, ev.Summary(), h.Command)
+		go func(h Hook) {
+			if err := h.Run(ev); err != nil {
+				log.Printf("Hook #%d failed: %s", h.ID, err)
+			}
+		}(h)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHookMatches(t *testing.T) {
+	maghrib := PrayTime{Key: "Maghrib"}
+	tests := []struct {
+		hook   Hook
+		offset time.Duration
+		want   bool
+	}{
+		{Hook{Prayer: "Maghrib"}, 0, true},
+		{Hook{Prayer: "maghrib"}, 0, true},
+		{Hook{Prayer: AllPrayers}, 0, true},
+		{Hook{Prayer: "Isyak"}, 0, false},
+		{Hook{Prayer: "Maghrib", Offset: 10}, 10 * time.Minute, true},
+		{Hook{Prayer: AllPrayers, Offset: 10}, 10 * time.Minute, true},
+		{Hook{Prayer: "Maghrib", Offset: -5}, -5 * time.Minute, true},
+		{Hook{Prayer: "Maghrib", Offset: 10}, 0, false},
+		{Hook{Prayer: "Maghrib"}, 10 * time.Minute, false},
+		{Hook{Prayer: "Maghrib", Offset: 5}, -5 * time.Minute, false},
+	}
+	for _, tt := range tests {
+		ev := Event{Prayer: maghrib, Offset: tt.offset}
+		if got := tt.hook.matches(ev); got != tt.want {
+			t.Errorf("hook %s offset %d, event offset %s: got %v, want %v", tt.hook.Prayer, tt.hook.Offset, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestHookEnv(t *testing.T) {
+	at := time.Date(2024, time.March, 12, 19, 25, 0, 0, JakimLocation)
+	h := &Hook{ID: 7, Prayer: "Maghrib", Offset: 10}
+	ev := Event{
+		Type:   EventReminder,
+		Prayer: PrayTime{Key: "Maghrib", Time: at, DisplayValue: "19:25", Offset: 5 * time.Minute},
+		ZoneID: "WLY01",
+		Zone:   &Zone{ID: "WLY01", Locations: "Kuala Lumpur, Putrajaya"},
+		Offset: 10 * time.Minute,
+	}
+	env := h.Env(ev)
+	sort.Strings(env)
+	want := []string{
+		"WS_ADJUSTMENT=5",
+		"WS_DISPLAY_TIME=19:25",
+		"WS_EVENT=reminder",
+		"WS_HOOK_ID=7",
+		"WS_LOCATIONS=Kuala Lumpur, Putrajaya",
+		"WS_OFFSET=10",
+		"WS_PRAYER=Maghrib",
+		"WS_PRAYER_NAME=Maghrib",
+		"WS_TIME=2024-03-12T19:25:00+08:00",
+		"WS_TIMESTAMP=1710242700",
+		"WS_ZONE=WLY01",
+	}
+	if strings.Join(env, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got\n%s\nwant\n%s", strings.Join(env, "\n"), strings.Join(want, "\n"))
+	}
+
+	ev.Zone = nil
+	for _, v := range h.Env(ev) {
+		if strings.HasPrefix(v, "WS_LOCATIONS=") {
+			t.Errorf("got %s without a zone", v)
+		}
+	}
+}
+
+func TestAddHookNormalizesPrayer(t *testing.T) {
+	ctx, _ := newTestCtx(t)
+	tests := []struct {
+		prayer string
+		want   string
+		err    bool
+	}{
+		{prayer: "maghrib", want: "Maghrib"},
+		{prayer: "ISYAK", want: "Isyak"},
+		{prayer: AllPrayers, want: AllPrayers},
+		{prayer: "", want: AllPrayers},
+		{prayer: "magrib", err: true},
+		{prayer: "Maghrib,Isyak", err: true},
+	}
+	for _, tt := range tests {
+		h := &Hook{Prayer: tt.prayer, Command: "true"}
+		err := AddHook(ctx, h)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: stored as %q, want an error", tt.prayer, h.Prayer)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", tt.prayer, err)
+		} else if stored := GetHook(ctx, h.ID); stored == nil || stored.Prayer != tt.want {
+			t.Errorf("%q: stored %+v, want prayer %q", tt.prayer, stored, tt.want)
+		}
+	}
+	if n := len(GetHooks(ctx)); n != 4 {
+		t.Errorf("%d hooks stored, want 4", n)
+	}
+}
@@ -0,0 +1,19 @@
+package services
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// shellCommand runs command with cmd.exe, passing it verbatim so its own quoting is kept
+func shellCommand(command string) *exec.Cmd {
+	cmd := exec.Command("cmd")
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: "cmd /C " + command}
+	return cmd
+}
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const icsTimeLayout = "20060102T150405Z"
+
+// ICSOptions controls the calendar produced by WriteICS
+type ICSOptions struct {
+	// Alarms adds a reminder this long before each prayer
+	Alarms []time.Duration
+	// EventLength is the duration of each event
+	EventLength time.Duration
+	// Prayers limits the events to these keys, every prayer is included when empty
+	Prayers []string
+}
+
+// WriteICS writes prayer times as an RFC 5545 calendar, one event per prayer
+func WriteICS(w io.Writer, dates []PrayerDate, opts ICSOptions) error {
+	bw := bufio.NewWriter(w)
+	line := func(format string, args ...any) {
+		writeICSLine(bw, fmt.Sprintf(format, args...))
+	}
+	include := func(key string) bool {
+		if len(opts.Prayers) == 0 {
+			return true
+		}
+		for _, p := range opts.Prayers {
+			if strings.EqualFold(p, key) {
+				return true
+			}
+		}
+		return false
+	}
+	stamp := time.Now().UTC().Format(icsTimeLayout)
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//waktu-solat//Prayer Times//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	if len(dates) != 0 {
+		line("X-WR-CALNAME:%s", escapeICSText(fmt.Sprintf("Waktu Solat %s", dates[0].ZoneID)))
+	}
+	for _, p := range dates {
+		location := ""
+		if p.Zone != nil {
+			location = p.Zone.Locations
+		}
+		for _, pt := range p.Times {
+			if !include(pt.Key) {
+				continue
+			}
+			line("BEGIN:VEVENT")
+			line("UID:%s-%s@waktu-solat", p.ID, strings.ToLower(pt.Key))
+			line("DTSTAMP:%s", stamp)
+			line("DTSTART:%s", pt.Time.UTC().Format(icsTimeLayout))
+			line("DTEND:%s", pt.Time.Add(opts.EventLength).UTC().Format(icsTimeLayout))
+			line("SUMMARY:%s", escapeICSText(pt.Name()))
+			description := fmt.Sprintf("%s %s%s (%s)\n%s", pt.Name(), pt.DisplayValue, pt.AdjustmentNote(), p.Hijri, location)
+			if note := p.CalculatedNote(); len(note) != 0 {
+				description += "\n" + note
+			}
+			line("DESCRIPTION:%s", escapeICSText(description))
+			if len(location) != 0 {
+				line("LOCATION:%s", escapeICSText(location))
+			}
+			line("TRANSP:TRANSPARENT")
+			for _, alarm := range opts.Alarms {
+				line("BEGIN:VALARM")
+				line("ACTION:DISPLAY")
+				line("DESCRIPTION:%s", escapeICSText(fmt.Sprintf("%s %s", pt.Name(), pt.DisplayValue)))
+				line("TRIGGER:-PT%dM", int(alarm/time.Minute))
+				line("END:VALARM")
+			}
+			line("END:VEVENT")
+		}
+	}
+	line("END:VCALENDAR")
+	return bw.Flush()
+}
+
+// writeICSLine writes a content line terminated by CRLF, folding it at 75 octets
+// without splitting a multibyte character
+func writeICSLine(w *bufio.Writer, s string) {
+	const limit = 75
+	first := true
+	for len(s) > 0 {
+		max := limit
+		if !first {
+			// Continuation lines start with a space which counts towards the limit
+			max--
+			_, _ = w.WriteString(" ")
+		}
+		n := len(s)
+		if n > max {
+			n = max
+			for n > 0 && s[n]&0xC0 == 0x80 {
+				n--
+			}
+		}
+		_, _ = w.WriteString(s[:n])
+		_, _ = w.WriteString("\r\n")
+		s = s[n:]
+		first = false
+	}
+}
+
+func escapeICSText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestWriteICSLineFolding(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"short", "SUMMARY:Maghrib"},
+		{"ascii", "DESCRIPTION:" + strings.Repeat("a", 200)},
+		{"multibyte", "SUMMARY:" + strings.Repeat("المغرب ", 30)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := bufio.NewWriter(&buf)
+			writeICSLine(w, tt.line)
+			_ = w.Flush()
+			out := buf.String()
+			if !strings.HasSuffix(out, "\r\n") {
+				t.Fatalf("%q does not end with CRLF", out)
+			}
+			var unfolded strings.Builder
+			for i, l := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+				if len(l) > 75 {
+					t.Errorf("line %d is %d octets", i, len(l))
+				}
+				if !utf8.ValidString(l) {
+					t.Errorf("line %d splits a character: %q", i, l)
+				}
+				if i > 0 {
+					if !strings.HasPrefix(l, " ") {
+						t.Errorf("continuation line %d doesn't start with a space", i)
+					}
+					l = l[1:]
+				}
+				unfolded.WriteString(l)
+			}
+			if unfolded.String() != tt.line {
+				t.Errorf("unfolded %q, want %q", unfolded.String(), tt.line)
+			}
+		})
+	}
+}
+
+func TestEscapeICSText(t *testing.T) {
+	got := escapeICSText("Kuala Lumpur, Putrajaya; a\\b\nc")
+	want := `Kuala Lumpur\, Putrajaya\; a\\b\nc`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWriteICS(t *testing.T) {
+	p := CalculatePrayerDate(3.1390, 101.6869, date(t, "2024-03-12"))
+	p.ID = "20240312-WLY01"
+	p.ZoneID = "WLY01"
+	p.Zone = &Zone{ID: "WLY01", Locations: "Kuala Lumpur, Putrajaya"}
+	p.init(displayOptions{layout: "15:04", location: JakimLocation})
+	var buf bytes.Buffer
+	err := WriteICS(&buf, []PrayerDate{p}, ICSOptions{EventLength: 15 * time.Minute, Prayers: []string{"maghrib"}, Alarms: []time.Duration{5 * time.Minute}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:20240312-WLY01-maghrib@waktu-solat\r\n",
+		// 19:28 in Malaysia
+		"DTSTART:20240312T112800Z\r\n",
+		"DTEND:20240312T114300Z\r\n",
+		"LOCATION:Kuala Lumpur\\, Putrajaya\r\n",
+		"TRIGGER:-PT5M\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "BEGIN:VEVENT"); n != 1 {
+		t.Errorf("got %d events, want only maghrib", n)
+	}
+}
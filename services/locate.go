@@ -0,0 +1,264 @@
+package services
+
+import (
+	"fmt"
+	"math"
+)
+
+// MaxLocateDistance is how far, in km, a coordinate may be from the nearest district to be in a zone
+const MaxLocateDistance = 50.0
+
+type district struct {
+	Name       string
+	ZoneID     string
+	Coordinate Coordinate
+	// Radius limits the match to this distance in km, used for small special zones like hilltops
+	Radius float64
+}
+
+// districts are approximate centroids of the main towns of each zone
+var districts = []district{
+	{"Pulau Aur", "JHR01", Coordinate{2.4500, 104.5200}, 10},
+	{"Pulau Pemanggil", "JHR01", Coordinate{2.5800, 104.3300}, 10},
+	{"Johor Bahru", "JHR02", Coordinate{1.4927, 103.7414}, 0},
+	{"Iskandar Puteri", "JHR02", Coordinate{1.4250, 103.6400}, 0},
+	{"Pasir Gudang", "JHR02", Coordinate{1.4726, 103.8780}, 0},
+	{"Kota Tinggi", "JHR02", Coordinate{1.7381, 103.8999}, 0},
+	{"Mersing", "JHR02", Coordinate{2.4312, 103.8405}, 0},
+	{"Kulai", "JHR02", Coordinate{1.6561, 103.6032}, 0},
+	{"Kluang", "JHR03", Coordinate{2.0301, 103.3185}, 0},
+	{"Pontian", "JHR03", Coordinate{1.4866, 103.3896}, 0},
+	{"Batu Pahat", "JHR04", Coordinate{1.8548, 102.9325}, 0},
+	{"Muar", "JHR04", Coordinate{2.0442, 102.5689}, 0},
+	{"Segamat", "JHR04", Coordinate{2.5148, 102.8158}, 0},
+	{"Tangkak", "JHR04", Coordinate{2.2673, 102.5453}, 0},
+	{"Gemas Johor", "JHR04", Coordinate{2.5800, 102.6100}, 0},
+	{"Alor Setar", "KDH01", Coordinate{6.1248, 100.3678}, 0},
+	{"Jitra", "KDH01", Coordinate{6.2683, 100.4219}, 0},
+	{"Pokok Sena", "KDH01", Coordinate{6.1700, 100.5200}, 0},
+	{"Sungai Petani", "KDH02", Coordinate{5.6470, 100.4877}, 0},
+	{"Yan", "KDH02", Coordinate{5.8000, 100.3833}, 0},
+	{"Pendang", "KDH02", Coordinate{5.9956, 100.4789}, 0},
+	{"Kuala Nerang", "KDH03", Coordinate{6.2500, 100.6100}, 0},
+	{"Sik", "KDH03", Coordinate{5.8167, 100.7333}, 0},
+	{"Baling", "KDH04", Coordinate{5.6766, 100.9171}, 0},
+	{"Kulim", "KDH05", Coordinate{5.3650, 100.5617}, 0},
+	{"Bandar Baharu", "KDH05", Coordinate{5.2100, 100.4900}, 0},
+	{"Langkawi", "KDH06", Coordinate{6.3256, 99.8432}, 0},
+	{"Gunung Jerai", "KDH07", Coordinate{5.7878, 100.4330}, 4},
+	{"Kota Bharu", "KTN01", Coordinate{6.1254, 102.2381}, 0},
+	{"Bachok", "KTN01", Coordinate{6.0667, 102.4000}, 0},
+	{"Machang", "KTN01", Coordinate{5.7667, 102.2167}, 0},
+	{"Pasir Mas", "KTN01", Coordinate{6.0500, 102.1333}, 0},
+	{"Pasir Puteh", "KTN01", Coordinate{5.8333, 102.4000}, 0},
+	{"Tanah Merah", "KTN01", Coordinate{5.8000, 102.1500}, 0},
+	{"Tumpat", "KTN01", Coordinate{6.2000, 102.1667}, 0},
+	{"Kuala Krai", "KTN01", Coordinate{5.5333, 102.2000}, 0},
+	{"Gua Musang", "KTN02", Coordinate{4.8823, 101.9644}, 0},
+	{"Jeli", "KTN02", Coordinate{5.7000, 101.8400}, 0},
+	{"Lojing", "KTN02", Coordinate{4.6000, 101.5000}, 0},
+	{"Melaka", "MLK01", Coordinate{2.1896, 102.2501}, 0},
+	{"Alor Gajah", "MLK01", Coordinate{2.3804, 102.2089}, 0},
+	{"Jasin", "MLK01", Coordinate{2.3096, 102.4281}, 0},
+	{"Tampin", "NGS01", Coordinate{2.4701, 102.2302}, 0},
+	{"Bahau", "NGS01", Coordinate{2.8100, 102.4100}, 0},
+	{"Kuala Klawang", "NGS02", Coordinate{2.9400, 102.0700}, 0},
+	{"Kuala Pilah", "NGS02", Coordinate{2.7389, 102.2487}, 0},
+	{"Rembau", "NGS02", Coordinate{2.5900, 102.0900}, 0},
+	{"Seremban", "NGS03", Coordinate{2.7259, 101.9424}, 0},
+	{"Port Dickson", "NGS03", Coordinate{2.5228, 101.7959}, 0},
+	{"Nilai", "NGS03", Coordinate{2.8150, 101.7980}, 0},
+	{"Pulau Tioman", "PHG01", Coordinate{2.7900, 104.1700}, 15},
+	{"Kuantan", "PHG02", Coordinate{3.8077, 103.3260}, 0},
+	{"Pekan", "PHG02", Coordinate{3.4836, 103.3996}, 0},
+	{"Rompin", "PHG02", Coordinate{2.8000, 103.4833}, 0},
+	{"Muadzam Shah", "PHG02", Coordinate{3.0500, 103.0833}, 0},
+	{"Temerloh", "PHG03", Coordinate{3.4500, 102.4176}, 0},
+	{"Jerantut", "PHG03", Coordinate{3.9360, 102.3626}, 0},
+	{"Maran", "PHG03", Coordinate{3.5866, 102.7730}, 0},
+	{"Bera", "PHG03", Coordinate{3.2780, 102.4400}, 0},
+	{"Jengka", "PHG03", Coordinate{3.7500, 102.5500}, 0},
+	{"Bentong", "PHG04", Coordinate{3.5220, 101.9080}, 0},
+	{"Raub", "PHG04", Coordinate{3.7930, 101.8570}, 0},
+	{"Kuala Lipis", "PHG04", Coordinate{4.1842, 102.0421}, 0},
+	{"Janda Baik", "PHG05", Coordinate{3.3300, 101.8600}, 5},
+	{"Bukit Tinggi", "PHG05", Coordinate{3.3500, 101.8200}, 4},
+	{"Cameron Highlands", "PHG06", Coordinate{4.4721, 101.3801}, 15},
+	{"Genting Highlands", "PHG06", Coordinate{3.4236, 101.7932}, 4},
+	{"Bukit Fraser", "PHG06", Coordinate{3.7120, 101.7360}, 4},
+	{"Kangar", "PLS01", Coordinate{6.4414, 100.1986}, 0},
+	{"Arau", "PLS01", Coordinate{6.4300, 100.2700}, 0},
+	{"Padang Besar", "PLS01", Coordinate{6.6600, 100.3200}, 0},
+	{"George Town", "PNG01", Coordinate{5.4141, 100.3288}, 0},
+	{"Butterworth", "PNG01", Coordinate{5.3991, 100.3638}, 0},
+	{"Bukit Mertajam", "PNG01", Coordinate{5.3630, 100.4667}, 0},
+	{"Balik Pulau", "PNG01", Coordinate{5.3500, 100.2333}, 0},
+	{"Nibong Tebal", "PNG01", Coordinate{5.1650, 100.4780}, 0},
+	{"Tapah", "PRK01", Coordinate{4.1986, 101.2614}, 0},
+	{"Bidor", "PRK01", Coordinate{4.1100, 101.2800}, 0},
+	{"Slim River", "PRK01", Coordinate{3.8300, 101.4000}, 0},
+	{"Tanjung Malim", "PRK01", Coordinate{3.6850, 101.5180}, 0},
+	{"Ipoh", "PRK02", Coordinate{4.5975, 101.0901}, 0},
+	{"Kuala Kangsar", "PRK02", Coordinate{4.7667, 100.9333}, 0},
+	{"Sungai Siput", "PRK02", Coordinate{4.8200, 101.0700}, 0},
+	{"Batu Gajah", "PRK02", Coordinate{4.4700, 101.0400}, 0},
+	{"Kampar", "PRK02", Coordinate{4.3000, 101.1500}, 0},
+	{"Gerik", "PRK03", Coordinate{5.4296, 101.1262}, 0},
+	{"Lenggong", "PRK03", Coordinate{5.1000, 100.9700}, 0},
+	{"Pengkalan Hulu", "PRK03", Coordinate{5.7000, 100.9900}, 0},
+	{"Temengor", "PRK04", Coordinate{5.5000, 101.3300}, 0},
+	{"Belum", "PRK04", Coordinate{5.6500, 101.4500}, 0},
+	{"Teluk Intan", "PRK05", Coordinate{4.0259, 101.0213}, 0},
+	{"Seri Iskandar", "PRK05", Coordinate{4.3600, 100.9800}, 0},
+	{"Sitiawan", "PRK05", Coordinate{4.2167, 100.7000}, 0},
+	{"Lumut", "PRK05", Coordinate{4.2333, 100.6333}, 0},
+	{"Bagan Datuk", "PRK05", Coordinate{3.9900, 100.7800}, 0},
+	{"Kampung Gajah", "PRK05", Coordinate{4.1833, 100.9333}, 0},
+	{"Parit", "PRK05", Coordinate{4.4700, 100.9100}, 0},
+	{"Beruas", "PRK05", Coordinate{4.5000, 100.7800}, 0},
+	{"Pulau Pangkor", "PRK05", Coordinate{4.2200, 100.5500}, 0},
+	{"Taiping", "PRK06", Coordinate{4.8500, 100.7333}, 0},
+	{"Parit Buntar", "PRK06", Coordinate{5.1250, 100.4930}, 0},
+	{"Bagan Serai", "PRK06", Coordinate{5.0100, 100.5400}, 0},
+	{"Selama", "PRK06", Coordinate{5.2200, 100.6900}, 0},
+	{"Bukit Larut", "PRK07", Coordinate{4.8620, 100.7930}, 3},
+	{"Sandakan", "SBH01", Coordinate{5.8402, 118.1179}, 0},
+	{"Sukau", "SBH01", Coordinate{5.5200, 118.2900}, 0},
+	{"Beluran", "SBH02", Coordinate{5.8944, 117.5550}, 0},
+	{"Telupid", "SBH02", Coordinate{5.6300, 117.1200}, 0},
+	{"Kuamut", "SBH02", Coordinate{5.2000, 117.4800}, 0},
+	{"Lahad Datu", "SBH03", Coordinate{5.0268, 118.3270}, 0},
+	{"Kunak", "SBH03", Coordinate{4.6833, 118.2500}, 0},
+	{"Semporna", "SBH03", Coordinate{4.4800, 118.6100}, 0},
+	{"Tawau", "SBH04", Coordinate{4.2448, 117.8912}, 0},
+	{"Kalabakan", "SBH04", Coordinate{4.4200, 117.4700}, 0},
+	{"Kudat", "SBH05", Coordinate{6.8837, 116.8477}, 0},
+	{"Kota Marudu", "SBH05", Coordinate{6.4900, 116.7600}, 0},
+	{"Pitas", "SBH05", Coordinate{6.7200, 117.0600}, 0},
+	{"Pulau Banggi", "SBH05", Coordinate{7.2600, 117.1000}, 0},
+	{"Gunung Kinabalu", "SBH06", Coordinate{6.0750, 116.5580}, 8},
+	{"Kota Kinabalu", "SBH07", Coordinate{5.9804, 116.0735}, 0},
+	{"Putatan", "SBH07", Coordinate{5.8900, 116.0500}, 0},
+	{"Penampang", "SBH07", Coordinate{5.9200, 116.1100}, 0},
+	{"Papar", "SBH07", Coordinate{5.7300, 115.9300}, 0},
+	{"Tuaran", "SBH07", Coordinate{6.1800, 116.2300}, 0},
+	{"Kota Belud", "SBH07", Coordinate{6.3510, 116.4300}, 0},
+	{"Ranau", "SBH07", Coordinate{5.9540, 116.6640}, 0},
+	{"Keningau", "SBH08", Coordinate{5.3378, 116.1602}, 0},
+	{"Tambunan", "SBH08", Coordinate{5.6700, 116.3600}, 0},
+	{"Nabawan", "SBH08", Coordinate{5.0500, 116.4400}, 0},
+	{"Pensiangan", "SBH08", Coordinate{4.5500, 116.3200}, 0},
+	{"Beaufort", "SBH09", Coordinate{5.3473, 115.7455}, 0},
+	{"Membakut", "SBH09", Coordinate{5.4600, 115.7800}, 0},
+	{"Kuala Penyu", "SBH09", Coordinate{5.5700, 115.6000}, 0},
+	{"Sipitang", "SBH09", Coordinate{5.0900, 115.5500}, 0},
+	{"Tenom", "SBH09", Coordinate{5.1200, 115.9500}, 0},
+	{"Long Pasia", "SBH09", Coordinate{4.4000, 115.7200}, 0},
+	{"Shah Alam", "SGR01", Coordinate{3.0733, 101.5185}, 0},
+	{"Petaling Jaya", "SGR01", Coordinate{3.1073, 101.6067}, 0},
+	{"Subang Jaya", "SGR01", Coordinate{3.0436, 101.5806}, 0},
+	{"Gombak", "SGR01", Coordinate{3.2500, 101.6500}, 0},
+	{"Rawang", "SGR01", Coordinate{3.3213, 101.5767}, 0},
+	{"Kajang", "SGR01", Coordinate{2.9927, 101.7909}, 0},
+	{"Cyberjaya", "SGR01", Coordinate{2.9213, 101.6559}, 0},
+	{"Sepang", "SGR01", Coordinate{2.6900, 101.7500}, 0},
+	{"Kuala Kubu Bharu", "SGR01", Coordinate{3.5667, 101.6500}, 0},
+	{"Kuala Selangor", "SGR02", Coordinate{3.3400, 101.2500}, 0},
+	{"Tanjung Karang", "SGR02", Coordinate{3.4200, 101.1800}, 0},
+	{"Sabak Bernam", "SGR02", Coordinate{3.7700, 100.9900}, 0},
+	{"Klang", "SGR03", Coordinate{3.0449, 101.4456}, 0},
+	{"Port Klang", "SGR03", Coordinate{3.0000, 101.4000}, 0},
+	{"Banting", "SGR03", Coordinate{2.8167, 101.5000}, 0},
+	{"Limbang", "SWK01", Coordinate{4.7548, 115.0089}, 0},
+	{"Lawas", "SWK01", Coordinate{4.8500, 115.4000}, 0},
+	{"Trusan", "SWK01", Coordinate{4.8000, 115.2100}, 0},
+	{"Sundar", "SWK01", Coordinate{4.8800, 115.2200}, 0},
+	{"Miri", "SWK02", Coordinate{4.3995, 113.9914}, 0},
+	{"Marudi", "SWK02", Coordinate{4.1800, 114.3200}, 0},
+	{"Niah", "SWK02", Coordinate{3.8500, 113.7500}, 0},
+	{"Bekenu", "SWK02", Coordinate{4.0600, 113.8300}, 0},
+	{"Bintulu", "SWK03", Coordinate{3.1713, 113.0419}, 0},
+	{"Tatau", "SWK03", Coordinate{2.8800, 112.8500}, 0},
+	{"Belaga", "SWK03", Coordinate{2.7000, 113.7800}, 0},
+	{"Sebauh", "SWK03", Coordinate{3.1100, 113.4400}, 0},
+	{"Sibu", "SWK04", Coordinate{2.2870, 111.8300}, 0},
+	{"Mukah", "SWK04", Coordinate{2.8988, 112.0914}, 0},
+	{"Dalat", "SWK04", Coordinate{2.7400, 111.9300}, 0},
+	{"Kanowit", "SWK04", Coordinate{2.1000, 112.1500}, 0},
+	{"Song", "SWK04", Coordinate{2.0000, 112.5500}, 0},
+	{"Kapit", "SWK04", Coordinate{2.0167, 112.9333}, 0},
+	{"Sarikei", "SWK05", Coordinate{2.1271, 111.5182}, 0},
+	{"Bintangor", "SWK05", Coordinate{2.1667, 111.6333}, 0},
+	{"Julau", "SWK05", Coordinate{2.0200, 111.9200}, 0},
+	{"Daro", "SWK05", Coordinate{2.5200, 111.4300}, 0},
+	{"Matu", "SWK05", Coordinate{2.6700, 111.5300}, 0},
+	{"Sri Aman", "SWK06", Coordinate{1.2376, 111.4621}, 0},
+	{"Betong", "SWK06", Coordinate{1.4100, 111.5300}, 0},
+	{"Saratok", "SWK06", Coordinate{1.7400, 111.3400}, 0},
+	{"Pusa", "SWK06", Coordinate{1.6000, 111.2400}, 0},
+	{"Engkilili", "SWK06", Coordinate{1.1200, 111.6500}, 0},
+	{"Lubok Antu", "SWK06", Coordinate{1.0400, 111.8300}, 0},
+	{"Kota Samarahan", "SWK07", Coordinate{1.4590, 110.4883}, 0},
+	{"Serian", "SWK07", Coordinate{1.1667, 110.5667}, 0},
+	{"Simunjan", "SWK07", Coordinate{1.3833, 110.7500}, 0},
+	{"Sebuyau", "SWK07", Coordinate{1.5200, 110.9300}, 0},
+	{"Kuching", "SWK08", Coordinate{1.5535, 110.3593}, 0},
+	{"Bau", "SWK08", Coordinate{1.4167, 110.1500}, 0},
+	{"Lundu", "SWK08", Coordinate{1.6667, 109.8500}, 0},
+	{"Sematan", "SWK08", Coordinate{1.8000, 109.7700}, 0},
+	{"Kampung Patarikan", "SWK09", Coordinate{4.9000, 115.4500}, 5},
+	{"Kuala Terengganu", "TRG01", Coordinate{5.3302, 103.1408}, 0},
+	{"Kuala Nerus", "TRG01", Coordinate{5.3900, 103.0800}, 0},
+	{"Marang", "TRG01", Coordinate{5.2056, 103.2058}, 0},
+	{"Jerteh", "TRG02", Coordinate{5.7333, 102.4914}, 0},
+	{"Kampung Raja", "TRG02", Coordinate{5.8000, 102.5600}, 0},
+	{"Bandar Permaisuri", "TRG02", Coordinate{5.5200, 102.7400}, 0},
+	{"Kuala Berang", "TRG03", Coordinate{5.0736, 103.0087}, 0},
+	{"Dungun", "TRG04", Coordinate{4.7566, 103.4160}, 0},
+	{"Paka", "TRG04", Coordinate{4.6400, 103.4400}, 0},
+	{"Kemaman", "TRG04", Coordinate{4.2333, 103.4167}, 0},
+	{"Kuala Lumpur", "WLY01", Coordinate{3.1390, 101.6869}, 0},
+	{"Putrajaya", "WLY01", Coordinate{2.9264, 101.6964}, 0},
+	{"Labuan", "WLY02", Coordinate{5.2831, 115.2308}, 0},
+}
+
+// Location is the zone found for a coordinate
+type Location struct {
+	ZoneID string `json:"zone_id"`
+	// District is the nearest known town
+	District string `json:"district"`
+	// Distance to the district in km
+	Distance float64 `json:"distance_km"`
+}
+
+// LocateZone finds the zone of a coordinate from the nearest district
+func LocateZone(lat float64, lon float64) (*Location, error) {
+	if err := ValidateCoordinate(lat, lon); err != nil {
+		return nil, err
+	}
+	var nearest *Location
+	for _, d := range districts {
+		distance := haversine(Coordinate{lat, lon}, d.Coordinate)
+		if d.Radius > 0 && distance > d.Radius {
+			continue
+		}
+		if nearest == nil || distance < nearest.Distance {
+			nearest = &Location{ZoneID: d.ZoneID, District: d.Name, Distance: distance}
+		}
+	}
+	if nearest == nil || nearest.Distance > MaxLocateDistance {
+		return nil, fmt.Errorf("coordinate [%f, %f] is not within any zone", lat, lon)
+	}
+	nearest.Distance = math.Round(nearest.Distance*10) / 10
+	return nearest, nil
+}
+
+// haversine returns the great circle distance between a and b in km
+func haversine(a Coordinate, b Coordinate) float64 {
+	const earthRadius = 6371.0
+	dLat := (b.Latitude - a.Latitude) * math.Pi / 180
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Pow(math.Sin(dLat/2), 2) +
+		math.Cos(a.Latitude*math.Pi/180)*math.Cos(b.Latitude*math.Pi/180)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLocateZone(t *testing.T) {
+	tests := []struct {
+		name     string
+		lat, lon float64
+		zoneId   string
+		district string
+	}{
+		{"KLCC", 3.1579, 101.7116, "WLY01", "Kuala Lumpur"},
+		{"George Town", 5.4141, 100.3288, "PNG01", "George Town"},
+		{"Kota Kinabalu", 5.9804, 116.0735, "SBH07", "Kota Kinabalu"},
+		{"Kuching", 1.5535, 110.3593, "SWK08", "Kuching"},
+		{"Johor Bahru", 1.4927, 103.7414, "JHR02", "Johor Bahru"},
+		// Hilltops are their own zone only within their radius
+		{"Genting Highlands", 3.4236, 101.7932, "PHG06", "Genting Highlands"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LocateZone(tt.lat, tt.lon)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.ZoneID != tt.zoneId || got.District != tt.district {
+				t.Errorf("got %s (%s), want %s (%s)", got.ZoneID, got.District, tt.zoneId, tt.district)
+			}
+		})
+	}
+}
+
+func TestLocateZoneOutside(t *testing.T) {
+	for _, c := range []Coordinate{{5.0, 110.0}, {13.7563, 100.5018}, {95, 0}} {
+		if got, err := LocateZone(c.Latitude, c.Longitude); err == nil {
+			t.Errorf("LocateZone(%v) = %+v, want an error", c, got)
+		}
+	}
+}
+
+func TestHaversine(t *testing.T) {
+	// Kuala Lumpur to George Town is about 293 km as the crow flies
+	d := haversine(Coordinate{3.1390, 101.6869}, Coordinate{5.4141, 100.3288})
+	if math.Abs(d-293) > 5 {
+		t.Errorf("got %.1f km, want about 293 km", d)
+	}
+	if d := haversine(Coordinate{3.1390, 101.6869}, Coordinate{3.1390, 101.6869}); d != 0 {
+		t.Errorf("got %f km between the same points", d)
+	}
+}
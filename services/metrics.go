@@ -0,0 +1,129 @@
+package services
+
+import (
+	"fmt"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const metricsPrefix = "waktu_solat_"
+
+// fetchCounts counts upstream fetches by kind (times or zones), provider and result since the process started
+var fetchCounts = struct {
+	sync.Mutex
+	counts map[[3]string]uint64
+}{counts: map[[3]string]uint64{}}
+
+// recordFetch counts an upstream fetch for the metrics endpoint
+func recordFetch(kind string, provider string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	fetchCounts.Lock()
+	defer fetchCounts.Unlock()
+	fetchCounts.counts[[3]string{kind, provider, result}]++
+}
+
+// metricsWriter writes the Prometheus text exposition format
+type metricsWriter struct {
+	w io.Writer
+}
+
+func (m *metricsWriter) header(name string, kind string, help string) {
+	_, _ = fmt.Fprintf(m.w, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricsPrefix, name, help, metricsPrefix, name, kind)
+}
+
+// labelEscaper escapes label values as the exposition format expects, only \, " and newlines
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// sample writes a value, labels are name and value pairs
+func (m *metricsWriter) sample(name string, value float64, labels ...string) {
+	var pairs []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], labelEscaper.Replace(labels[i+1])))
+	}
+	formatted := strconv.FormatFloat(value, 'f', -1, 64)
+	if len(pairs) == 0 {
+		_, _ = fmt.Fprintf(m.w, "%s%s %s\n", metricsPrefix, name, formatted)
+		return
+	}
+	_, _ = fmt.Fprintf(m.w, "%s%s{%s} %s\n", metricsPrefix, name, strings.Join(pairs, ","), formatted)
+}
+
+// WriteMetrics writes prayer time, cache and fetch metrics of zones
+func WriteMetrics(ctx *common.Ctx, w io.Writer, zoneIds []string, now time.Time) {
+	m := &metricsWriter{w: w}
+	var nexts []*NextPrayer
+	var todays []PrayerDate
+	for _, zoneId := range zoneIds {
+		if next, err := GetNextPrayer(ctx, zoneId, now); err == nil {
+			nexts = append(nexts, next)
+		}
+		today := Today()
+		todays = append(todays, GetPrayerTimes(ctx, zoneId, today, today)...)
+	}
+
+	m.header("next_prayer_seconds", "gauge", "Seconds until the next prayer of the zone.")
+	for _, n := range nexts {
+		m.sample("next_prayer_seconds", n.Remaining.Seconds(), "zone", n.ZoneID, "prayer", n.Next.Key)
+	}
+	m.header("prayer_timestamp_seconds", "gauge", "Unix time of each of today's prayers of the zone.")
+	for _, p := range todays {
+		for _, pt := range p.Times {
+			m.sample("prayer_timestamp_seconds", float64(pt.Time.Unix()), "zone", p.ZoneID, "prayer", pt.Key)
+		}
+	}
+
+	var records []FetchRecord
+	if db, err := OpenDb(ctx); err == nil {
+		db.Order("id").Find(&records)
+	} else {
+		log.Println(err)
+	}
+	m.header("cache_fetched_timestamp_seconds", "gauge", "Unix time prayer times of the zone and year were last received.")
+	for _, r := range records {
+		if r.FetchedAt.IsZero() {
+			continue
+		}
+		m.sample("cache_fetched_timestamp_seconds", float64(r.FetchedAt.Unix()), "zone", r.ZoneID, "year", fmt.Sprint(r.Year), "provider", r.Provider)
+	}
+	m.header("cache_checked_timestamp_seconds", "gauge", "Unix time of the last fetch attempt for the zone and year.")
+	for _, r := range records {
+		m.sample("cache_checked_timestamp_seconds", float64(r.CheckedAt.Unix()), "zone", r.ZoneID, "year", fmt.Sprint(r.Year))
+	}
+	m.header("cache_days", "gauge", "Days of prayer times received on the last fetch for the zone and year.")
+	for _, r := range records {
+		m.sample("cache_days", float64(r.Count), "zone", r.ZoneID, "year", fmt.Sprint(r.Year))
+	}
+
+	m.header("upstream_fetches_total", "counter", "Upstream fetches since the process started by kind, provider and result.")
+	fetchCounts.Lock()
+	keys := make([][3]string, 0, len(fetchCounts.counts))
+	for k := range fetchCounts.counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return strings.Join(keys[i][:], " ") < strings.Join(keys[j][:], " ")
+	})
+	for _, k := range keys {
+		m.sample("upstream_fetches_total", float64(fetchCounts.counts[k]), "kind", k[0], "provider", k[1], "result", k[2])
+	}
+	fetchCounts.Unlock()
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter) {
+	zoneIds := s.MetricsZones
+	if len(zoneIds) == 0 {
+		zoneIds = []string{GetConfig(s.Ctx, ConfigZone)}
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	WriteMetrics(s.Ctx, w, zoneIds, time.Now())
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricsLabelEscaping(t *testing.T) {
+	var buf bytes.Buffer
+	m := &metricsWriter{w: &buf}
+	m.sample("test", 1, "location", "Kota Bharu \"Kelantan\"\\\nSelatan", "name", "Ṣubḥ")
+	want := metricsPrefix + `test{location="Kota Bharu \"Kelantan\"\\\nSelatan",name="Ṣubḥ"} 1` + "\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	ctx, provider := newTestCtx(t)
+	now := time.Now()
+	var buf bytes.Buffer
+	WriteMetrics(ctx, &buf, []string{"WLY01"}, now)
+	year := Today().Year()
+	for _, want := range []string{
+		metricsPrefix + `prayer_timestamp_seconds{zone="WLY01",prayer="Maghrib"} `,
+		metricsPrefix + fmt.Sprintf(`cache_fetched_timestamp_seconds{zone="WLY01",year="%d",provider="%s"} `, year, provider.name),
+		metricsPrefix + fmt.Sprintf(`cache_checked_timestamp_seconds{zone="WLY01",year="%d"} `, year),
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("missing %s in\n%s", want, buf.String())
+		}
+	}
+
+	before := openFiles(t)
+	for i := 0; i < 50; i++ {
+		WriteMetrics(ctx, io.Discard, []string{"WLY01"}, now)
+	}
+	if after := openFiles(t); after > before+2 {
+		t.Errorf("%d files open after 50 scrapes, %d before", after, before)
+	}
+	if n := provider.fetches(year); n != 1 {
+		t.Errorf("provider called %d times, want 1 as scrapes read the cache", n)
+	}
+}
@@ -0,0 +1,365 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultMQTTBroker          = "tcp://localhost:1883"
+	DefaultMQTTTopic           = "waktu-solat"
+	DefaultMQTTDiscoveryPrefix = "homeassistant"
+
+	mqttStatusOnline  = "online"
+	mqttStatusOffline = "offline"
+
+	// mqttZoneKey is the UserConfig key prefix recording the zone last published under a topic
+	mqttZoneKey = "MQTT_ZONE:"
+)
+
+// MQTTOptions configure the broker connection and topics of an MQTTNotifier
+type MQTTOptions struct {
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// Topic is the prefix of every topic, states are published to <topic>/<zone>/{today,current,next}
+	// and prayer events to <topic>/<zone>/event
+	Topic string
+	// DiscoveryPrefix is where Home Assistant discovery configs are published, disabled when empty
+	DiscoveryPrefix string
+	Timeout         time.Duration
+}
+
+// MQTTNotifier publishes the prayer times of a zone as retained messages, announces them to Home
+// Assistant and publishes an event when each prayer starts
+type MQTTNotifier struct {
+	Ctx *common.Ctx
+	// ZoneID is the zone to publish, the default zone is re-read on every refresh when empty
+	ZoneID  string
+	Options MQTTOptions
+	client  mqtt.Client
+
+	// refreshMu serializes Refresh, which runs from the ticker, the connect handler and Notify
+	refreshMu sync.Mutex
+	mu        sync.Mutex
+	// published is the last payload of each retained topic, so unchanged states aren't sent again
+	published map[string]string
+	// discovered are the zones whose discovery configs were sent since the last connection
+	discovered map[string]bool
+}
+
+// MQTTTodayResponse is today's timetable with each prayer keyed by its lower case name, for
+// templates such as {{ value_json.prayers.maghrib }}
+type MQTTTodayResponse struct {
+	PrayerDateResponse
+	Prayers map[string]time.Time `json:"prayers"`
+}
+
+func NewMQTTNotifier(ctx *common.Ctx, zoneId string, options MQTTOptions) (*MQTTNotifier, error) {
+	if len(options.Broker) == 0 {
+		options.Broker = DefaultMQTTBroker
+	}
+	if len(options.Topic) == 0 {
+		options.Topic = DefaultMQTTTopic
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = 10 * time.Second
+	}
+	options.Topic = strings.TrimSuffix(options.Topic, "/")
+	options.DiscoveryPrefix = strings.TrimSuffix(options.DiscoveryPrefix, "/")
+	n := &MQTTNotifier{
+		Ctx:        ctx,
+		ZoneID:     strings.ToUpper(zoneId),
+		Options:    options,
+		published:  map[string]string{},
+		discovered: map[string]bool{},
+	}
+	clientOptions := mqtt.NewClientOptions().
+		AddBroker(options.Broker).
+		SetClientID(options.ClientID).
+		SetUsername(options.Username).
+		SetPassword(options.Password).
+		SetWill(n.statusTopic(), mqttStatusOffline, 1, true).
+		SetAutoReconnect(true).
+		SetOnConnectHandler(n.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("Lost connection to MQTT broker %s: %s", options.Broker, err)
+		})
+	n.client = mqtt.NewClient(clientOptions)
+	token := n.client.Connect()
+	if !token.WaitTimeout(options.Timeout) {
+		return nil, fmt.Errorf("timed out connecting to MQTT broker %s", options.Broker)
+	}
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("unable to connect to MQTT broker %s: %w", options.Broker, err)
+	}
+	return n, nil
+}
+
+// onConnect marks the publisher online and forgets what was published, so discovery configs and
+// states are sent again in case the broker lost its retained messages
+func (n *MQTTNotifier) onConnect(_ mqtt.Client) {
+	n.mu.Lock()
+	n.published = map[string]string{}
+	n.discovered = map[string]bool{}
+	n.mu.Unlock()
+	go func() {
+		if err := n.publish(n.statusTopic(), true, mqttStatusOnline); err != nil {
+			log.Printf("Unable to publish MQTT status: %s", err)
+		}
+		n.Refresh()
+	}()
+}
+
+// Offsets is empty, reminders aren't published, only the prayer events
+func (n *MQTTNotifier) Offsets() []time.Duration {
+	return nil
+}
+
+func (n *MQTTNotifier) Notify(ev Event) error {
+	if ev.Type != EventPrayer {
+		return nil
+	}
+	bytes, err := json.Marshal(ev.ToJSONResponse())
+	if err != nil {
+		return err
+	}
+	if err := n.publish(n.zoneTopic(ev.ZoneID, "event"), false, string(bytes)); err != nil {
+		return err
+	}
+	n.Refresh()
+	return nil
+}
+
+// Run refreshes the retained states every minute until runCtx is cancelled
+func (n *MQTTNotifier) Run(runCtx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-runCtx.Done():
+			return
+		case <-ticker.C:
+			n.Refresh()
+		}
+	}
+}
+
+// Close marks the publisher offline and disconnects from the broker
+func (n *MQTTNotifier) Close() {
+	if err := n.publish(n.statusTopic(), true, mqttStatusOffline); err != nil {
+		log.Printf("Unable to publish MQTT status: %s", err)
+	}
+	n.client.Disconnect(250)
+}
+
+// Refresh publishes today's timetable and the current and next prayer, along with the discovery
+// configs the first time a zone is seen
+func (n *MQTTNotifier) Refresh() {
+	n.refreshMu.Lock()
+	defer n.refreshMu.Unlock()
+	zoneId := strings.ToUpper(n.ZoneID)
+	if len(zoneId) == 0 {
+		zoneId = strings.ToUpper(GetConfig(n.Ctx, ConfigZone))
+	}
+	lastZoneKey := mqttZoneKey + n.Options.Topic
+	if lastZone := GetUserConfig(n.Ctx, lastZoneKey, ""); lastZone != zoneId {
+		if len(lastZone) != 0 {
+			if err := n.forget(lastZone); err != nil {
+				log.Printf("Unable to remove the MQTT topics of zone [%s]: %s", lastZone, err)
+				return
+			}
+		}
+		SetUserConfig(n.Ctx, lastZoneKey, zoneId)
+	}
+	now := time.Now()
+	today := Today()
+	prayerDates := GetPrayerTimes(n.Ctx, zoneId, today, today)
+	if len(prayerDates) == 0 {
+		log.Printf("No prayer times found for zone [%s], nothing published to MQTT", zoneId)
+		return
+	}
+	p := prayerDates[0]
+	if err := n.discover(zoneId, p.Zone); err != nil {
+		log.Printf("Unable to publish Home Assistant discovery for zone [%s]: %s", zoneId, err)
+	}
+	states := map[string]any{}
+	todayResponse := MQTTTodayResponse{PrayerDateResponse: p.ToJSONResponse(), Prayers: map[string]time.Time{}}
+	for _, pt := range p.Times {
+		todayResponse.Prayers[strings.ToLower(pt.Key)] = pt.Time
+	}
+	states["today"] = todayResponse
+	if next, err := GetNextPrayer(n.Ctx, zoneId, now); err == nil {
+		states["next"] = next.ToJSONResponse()
+		// Before the first prayer of the day there is none, clear the previous one
+		states["current"] = struct{}{}
+		if next.Current != nil {
+			states["current"] = next.Current.ToJSONResponse()
+		}
+	} else {
+		log.Printf("Unable to find the next prayer of zone [%s]: %s", zoneId, err)
+	}
+	for name, state := range states {
+		bytes, err := json.Marshal(state)
+		if err != nil {
+			log.Printf("Unable to encode MQTT %s state: %s", name, err)
+			continue
+		}
+		if err := n.publishRetained(n.zoneTopic(zoneId, name), string(bytes)); err != nil {
+			log.Printf("Unable to publish MQTT %s state: %s", name, err)
+		}
+	}
+}
+
+// mqttDiscoveryConfig is a Home Assistant MQTT sensor discovery payload
+type mqttDiscoveryConfig struct {
+	Name                string              `json:"name"`
+	UniqueID            string              `json:"unique_id"`
+	StateTopic          string              `json:"state_topic"`
+	ValueTemplate       string              `json:"value_template"`
+	JSONAttributesTopic string              `json:"json_attributes_topic,omitempty"`
+	DeviceClass         string              `json:"device_class,omitempty"`
+	Icon                string              `json:"icon,omitempty"`
+	AvailabilityTopic   string              `json:"availability_topic"`
+	Device              mqttDiscoveryDevice `json:"device"`
+}
+
+type mqttDiscoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model,omitempty"`
+}
+
+// discover publishes a sensor for each prayer time of the day, plus the current and next prayer
+func (n *MQTTNotifier) discover(zoneId string, zone *Zone) error {
+	if len(n.Options.DiscoveryPrefix) == 0 {
+		return nil
+	}
+	n.mu.Lock()
+	done := n.discovered[zoneId]
+	n.mu.Unlock()
+	if done {
+		return nil
+	}
+	id := "waktu_solat_" + strings.ToLower(zoneId)
+	device := mqttDiscoveryDevice{
+		Identifiers:  []string{id},
+		Name:         "Waktu Solat " + zoneId,
+		Manufacturer: "JAKIM",
+	}
+	if zone != nil {
+		device.Model = zone.Locations
+	}
+	sensor := func(key string, name string, state string, template string) mqttDiscoveryConfig {
+		return mqttDiscoveryConfig{
+			Name:              name,
+			UniqueID:          id + "_" + key,
+			StateTopic:        n.zoneTopic(zoneId, state),
+			ValueTemplate:     template,
+			AvailabilityTopic: n.statusTopic(),
+			Device:            device,
+		}
+	}
+	var configs []mqttDiscoveryConfig
+	for _, key := range PrayerKeys() {
+		lower := strings.ToLower(key)
+		config := sensor(lower, PrayerName(key), "today", fmt.Sprintf("{{ value_json.prayers.%s }}", lower))
+		config.DeviceClass = "timestamp"
+		configs = append(configs, config)
+	}
+	current := sensor("current_prayer", common.T("Current prayer"), "current", "{{ value_json.name | default('') }}")
+	current.Icon = "mdi:mosque"
+	next := sensor("next_prayer", common.T("Next prayer"), "next", "{{ value_json.prayer.name }}")
+	next.JSONAttributesTopic = n.zoneTopic(zoneId, "next")
+	next.Icon = "mdi:mosque"
+	nextTime := sensor("next_prayer_time", common.T("Next prayer time"), "next", "{{ value_json.prayer.time }}")
+	nextTime.DeviceClass = "timestamp"
+	configs = append(configs, current, next, nextTime)
+	for _, config := range configs {
+		bytes, err := json.Marshal(config)
+		if err != nil {
+			return err
+		}
+		if err := n.publishRetained(n.discoveryTopic(config.UniqueID), string(bytes)); err != nil {
+			return err
+		}
+	}
+	n.mu.Lock()
+	n.discovered[zoneId] = true
+	n.mu.Unlock()
+	return nil
+}
+
+// forget clears the retained states and discovery configs of a zone no longer published, so Home
+// Assistant removes its sensors
+func (n *MQTTNotifier) forget(zoneId string) error {
+	var topics []string
+	for _, name := range []string{"today", "current", "next"} {
+		topics = append(topics, n.zoneTopic(zoneId, name))
+	}
+	if len(n.Options.DiscoveryPrefix) != 0 {
+		id := "waktu_solat_" + strings.ToLower(zoneId)
+		keys := []string{"current_prayer", "next_prayer", "next_prayer_time"}
+		for _, key := range PrayerKeys() {
+			keys = append(keys, strings.ToLower(key))
+		}
+		for _, key := range keys {
+			topics = append(topics, n.discoveryTopic(id+"_"+key))
+		}
+	}
+	for _, topic := range topics {
+		if err := n.publishRetained(topic, ""); err != nil {
+			return err
+		}
+	}
+	n.mu.Lock()
+	delete(n.discovered, zoneId)
+	n.mu.Unlock()
+	return nil
+}
+
+func (n *MQTTNotifier) discoveryTopic(uniqueId string) string {
+	return fmt.Sprintf("%s/sensor/%s/config", n.Options.DiscoveryPrefix, uniqueId)
+}
+
+func (n *MQTTNotifier) statusTopic() string {
+	return n.Options.Topic + "/status"
+}
+
+func (n *MQTTNotifier) zoneTopic(zoneId string, name string) string {
+	return fmt.Sprintf("%s/%s/%s", n.Options.Topic, strings.ToLower(zoneId), name)
+}
+
+// publishRetained publishes a retained payload unless it is the one last published to the topic
+func (n *MQTTNotifier) publishRetained(topic string, payload string) error {
+	n.mu.Lock()
+	last, ok := n.published[topic]
+	n.mu.Unlock()
+	if ok && last == payload {
+		return nil
+	}
+	if err := n.publish(topic, true, payload); err != nil {
+		return err
+	}
+	n.mu.Lock()
+	n.published[topic] = payload
+	n.mu.Unlock()
+	return nil
+}
+
+func (n *MQTTNotifier) publish(topic string, retained bool, payload string) error {
+	token := n.client.Publish(topic, 1, retained, payload)
+	if !token.WaitTimeout(n.Options.Timeout) {
+		return fmt.Errorf("timed out publishing to %s", topic)
+	}
+	return token.Error()
+}
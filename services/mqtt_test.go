@@ -0,0 +1,121 @@
+package services
+
+import (
+	"fmt"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestMQTTNotifier runs against a real broker, e.g.
+//
+//	mosquitto -p 1883 &
+//	WS_MQTT_TEST_BROKER=tcp://localhost:1883 go test ./services -run MQTT
+func TestMQTTNotifier(t *testing.T) {
+	broker := os.Getenv(common.ENV_PREFIX + "MQTT_TEST_BROKER")
+	if len(broker) == 0 {
+		t.Skip(common.ENV_PREFIX + "MQTT_TEST_BROKER not set")
+	}
+	dir := t.TempDir()
+	ctx := &common.Ctx{Config: &common.Config{
+		DbPath:     filepath.Join(dir, "ws.db"),
+		ConfigPath: filepath.Join(dir, "config.toml"),
+		Provider:   OfflineProvider,
+	}}
+	t.Cleanup(func() {
+		CloseDb(ctx)
+	})
+	suffix := fmt.Sprint(time.Now().UnixNano())
+	options := MQTTOptions{
+		Broker:          broker,
+		Topic:           "waktu-solat-test-" + suffix,
+		DiscoveryPrefix: "homeassistant-test-" + suffix,
+		Timeout:         5 * time.Second,
+	}
+
+	var mu sync.Mutex
+	received := map[string]string{}
+	subscriber := mqtt.NewClient(mqtt.NewClientOptions().AddBroker(broker))
+	if token := subscriber.Connect(); !token.WaitTimeout(options.Timeout) || token.Error() != nil {
+		t.Fatalf("unable to connect to %s: %v", broker, token.Error())
+	}
+	defer subscriber.Disconnect(250)
+	onMessage := func(_ mqtt.Client, msg mqtt.Message) {
+		mu.Lock()
+		received[msg.Topic()] = string(msg.Payload())
+		mu.Unlock()
+	}
+	for _, filter := range []string{options.Topic + "/#", options.DiscoveryPrefix + "/#"} {
+		if token := subscriber.Subscribe(filter, 1, onMessage); !token.WaitTimeout(options.Timeout) || token.Error() != nil {
+			t.Fatalf("unable to subscribe to %s: %v", filter, token.Error())
+		}
+	}
+	waitFor := func(topic string, check func(payload string) bool) {
+		t.Helper()
+		deadline := time.Now().Add(options.Timeout)
+		for time.Now().Before(deadline) {
+			mu.Lock()
+			payload, ok := received[topic]
+			mu.Unlock()
+			if ok && check(payload) {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		t.Fatalf("%s: unexpected payload %q", topic, received[topic])
+	}
+	notEmpty := func(payload string) bool { return len(payload) != 0 }
+
+	n, err := NewMQTTNotifier(ctx, "SGR01", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Refresh()
+	waitFor(options.Topic+"/status", func(payload string) bool { return payload == mqttStatusOnline })
+	for _, name := range []string{"today", "current", "next"} {
+		waitFor(options.Topic+"/sgr01/"+name, notEmpty)
+	}
+	oldConfig := options.DiscoveryPrefix + "/sensor/waktu_solat_sgr01_maghrib/config"
+	waitFor(oldConfig, notEmpty)
+
+	// Switching zone removes the sensors of the old one
+	n.ZoneID = "JHR01"
+	n.Refresh()
+	waitFor(options.DiscoveryPrefix+"/sensor/waktu_solat_jhr01_maghrib/config", notEmpty)
+	waitFor(oldConfig, func(payload string) bool { return len(payload) == 0 })
+	waitFor(options.Topic+"/sgr01/today", func(payload string) bool { return len(payload) == 0 })
+
+	n.Close()
+	waitFor(options.Topic+"/status", func(payload string) bool { return payload == mqttStatusOffline })
+}
+
+// TestMQTTRefreshReusesDb refreshes while disconnected, publishing fails but the zone and times
+// are still read on every refresh
+func TestMQTTRefreshReusesDb(t *testing.T) {
+	ctx, _ := newTestCtx(t)
+	n := &MQTTNotifier{
+		Ctx:        ctx,
+		ZoneID:     "WLY01",
+		Options:    MQTTOptions{Topic: DefaultMQTTTopic, Timeout: time.Second},
+		client:     mqtt.NewClient(mqtt.NewClientOptions().AddBroker(DefaultMQTTBroker)),
+		published:  map[string]string{},
+		discovered: map[string]bool{},
+	}
+	n.Refresh()
+	if zone := GetUserConfig(ctx, mqttZoneKey+n.Options.Topic, ""); zone != "WLY01" {
+		t.Fatalf("got last zone %q, want WLY01", zone)
+	}
+	before := openFiles(t)
+	for i := 0; i < 50; i++ {
+		n.Refresh()
+	}
+	if after := openFiles(t); after > before+2 {
+		t.Errorf("%d files open after 50 refreshes, %d before", after, before)
+	}
+}
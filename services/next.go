@@ -0,0 +1,112 @@
+package services
+
+import (
+	"fmt"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"strings"
+	"time"
+)
+
+// NextPrayer is the upcoming prayer of a zone, which may be on the following day
+type NextPrayer struct {
+	ZoneID string
+	Zone   *Zone
+	// Current is the latest prayer that has started, nil when it isn't cached
+	Current   *PrayTime
+	Next      PrayTime
+	Remaining time.Duration
+	// PrayerDate is the day of the next prayer
+	PrayerDate *PrayerDate
+}
+
+type NextPrayerResponse struct {
+	Zone             ZoneResponse      `json:"zone"`
+	Prayer           PrayTimeResponse  `json:"prayer"`
+	Current          *PrayTimeResponse `json:"current,omitempty"`
+	SecondsRemaining int64             `json:"seconds_remaining"`
+	Calculated       bool              `json:"calculated"`
+}
+
+// GetNextPrayer finds the prayer following now, looking from yesterday's times, for a current prayer
+// started before midnight, up to tomorrow's
+func GetNextPrayer(ctx *common.Ctx, zoneId string, now time.Time) (*NextPrayer, error) {
+	local := now.In(JakimLocation)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, JakimLocation)
+	var res *NextPrayer
+	var current *PrayTime
+	prayerDates := GetPrayerTimes(ctx, zoneId, day.AddDate(0, 0, -1), day.AddDate(0, 0, 1))
+	for d, p := range prayerDates {
+		for i := range p.Times {
+			pt := p.Times[i]
+			if !pt.Time.After(now) {
+				current = &pt
+				continue
+			}
+			pt.IsCurrent = false
+			res = &NextPrayer{ZoneID: p.ZoneID, Zone: p.Zone, Next: pt, Remaining: pt.Time.Sub(now), PrayerDate: &prayerDates[d]}
+			break
+		}
+		if res != nil {
+			break
+		}
+	}
+	if res == nil {
+		return nil, fmt.Errorf("no upcoming prayer time found for zone [%s]", strings.ToUpper(zoneId))
+	}
+	if current != nil {
+		current.IsCurrent = true
+		current.Duration = current.Time.Sub(now)
+		res.Current = current
+	}
+	res.Next.Duration = res.Remaining
+	return res, nil
+}
+
+// Summary is a one line description such as "Asar in 1hour 5min"
+func (n *NextPrayer) Summary() string {
+	return common.T("%s in %s", n.Next.Name(), common.Timespan(n.Remaining.Round(time.Second)).Format())
+}
+
+// Short is a terse form for status bars such as "Asar 04:18PM -1:05"
+func (n *NextPrayer) Short() string {
+	remaining := n.Remaining.Round(time.Minute)
+	return fmt.Sprintf("%s %s%s -%d:%02d", n.Next.Name(), n.Next.DisplayValue, n.Next.AdjustmentNote(),
+		int(remaining/time.Hour), int(remaining%time.Hour/time.Minute))
+}
+
+func (n *NextPrayer) ToJSONResponse() NextPrayerResponse {
+	res := NextPrayerResponse{
+		Zone:             ZoneResponse{ID: n.ZoneID},
+		Prayer:           n.Next.ToJSONResponse(),
+		SecondsRemaining: int64(n.Remaining / time.Second),
+	}
+	if n.Zone != nil {
+		res.Zone = n.Zone.ToJSONResponse()
+	}
+	if n.Current != nil {
+		res.Current = common.Ptr(n.Current.ToJSONResponse())
+	}
+	if n.PrayerDate != nil {
+		res.Calculated = n.PrayerDate.Calculated
+	}
+	return res
+}
+
+func (n *NextPrayer) ToAlfredResponse() common.AlfredResponse {
+	subtitle := n.Next.DisplayValue + n.Next.AdjustmentNote()
+	res := common.AlfredResponse{}
+	if n.Zone != nil {
+		subtitle = fmt.Sprintf("%s | %s", subtitle, n.Zone.Locations)
+		res.Variables = map[string]string{"location": n.Zone.Locations}
+	}
+	if n.PrayerDate != nil && n.PrayerDate.Calculated {
+		subtitle = fmt.Sprintf("%s | %s", subtitle, common.T("calculated"))
+	}
+	res.AddItem(common.AlfredResponseItem{
+		Title:    n.Summary(),
+		Subtitle: &subtitle,
+		Arg:      n.ZoneID,
+		Valid:    true,
+	})
+	return res
+}
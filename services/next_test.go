@@ -0,0 +1,49 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetNextPrayer(t *testing.T) {
+	ctx, _ := newTestCtx(t)
+	at := func(value string) time.Time {
+		res, err := time.ParseInLocation("2006-01-02 15:04", value, JakimLocation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	tests := []struct {
+		now       string
+		next      string
+		nextDate  string
+		current   string
+		remaining time.Duration
+	}{
+		{"2024-03-12 12:00", "Zohor", "12/03/2024", "Syuruk", 75 * time.Minute},
+		{"2024-03-12 13:15", "Asar", "12/03/2024", "Zohor", 200 * time.Minute},
+		// After isyak the next prayer is the following day's imsak
+		{"2024-03-12 21:00", "Imsak", "13/03/2024", "Isyak", 8*time.Hour + 50*time.Minute},
+		// Before imsak the current prayer is the previous day's isyak
+		{"2024-03-12 03:00", "Imsak", "12/03/2024", "Isyak", 2*time.Hour + 50*time.Minute},
+		{"2024-03-12 05:55", "Subuh", "12/03/2024", "Imsak", 5 * time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.now, func(t *testing.T) {
+			next, err := GetNextPrayer(ctx, "WLY01", at(tt.now))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next.Next.Key != tt.next || next.PrayerDate.Date != tt.nextDate {
+				t.Errorf("next is %s on %s, want %s on %s", next.Next.Key, next.PrayerDate.Date, tt.next, tt.nextDate)
+			}
+			if next.Current == nil || next.Current.Key != tt.current {
+				t.Errorf("current is %+v, want %s", next.Current, tt.current)
+			}
+			if next.Remaining != tt.remaining {
+				t.Errorf("remaining %s, want %s", next.Remaining, tt.remaining)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"io"
+	"time"
+)
+
+// EventResponse is the JSON form of an Event
+type EventResponse struct {
+	Type          string        `json:"type"`
+	Prayer        string        `json:"prayer"`
+	Zone          *ZoneResponse `json:"zone,omitempty"`
+	Time          time.Time     `json:"time"`
+	At            time.Time     `json:"at"`
+	OffsetMinutes int           `json:"offset_minutes"`
+}
+
+func (e *Event) ToJSONResponse() EventResponse {
+	res := EventResponse{
+		Type:          e.Type,
+		Prayer:        e.Prayer.Key,
+		Time:          e.Prayer.Time,
+		At:            e.At,
+		OffsetMinutes: int(e.Offset / time.Minute),
+	}
+	if e.Zone != nil {
+		zone := e.Zone.ToJSONResponse()
+		res.Zone = &zone
+	} else {
+		res.Zone = &ZoneResponse{ID: e.ZoneID}
+	}
+	return res
+}
+
+// Summary is a short human readable description of the event
+func (e *Event) Summary() string {
+	if e.Type == EventReminder && e.Offset > 0 {
+		return common.T("%s in %s", e.Prayer.Name(), common.Timespan(e.Offset).Format())
+	} else if e.Type == EventReminder {
+		return common.T("%s %s ago", e.Prayer.Name(), common.Timespan(-e.Offset).Format())
+	}
+	return e.Prayer.Name()
+}
+
+// Body describes when and where the prayer is
+func (e *Event) Body() string {
+	if e.Zone != nil {
+		return fmt.Sprintf("%s%s | %s", e.Prayer.DisplayValue, e.Prayer.AdjustmentNote(), e.Zone.Locations)
+	}
+	return fmt.Sprintf("%s%s | %s", e.Prayer.DisplayValue, e.Prayer.AdjustmentNote(), e.ZoneID)
+}
+
+// StreamNotifier writes each event as a line, JSON encoded when IsJSON is set
+type StreamNotifier struct {
+	Writer io.Writer
+	IsJSON bool
+}
+
+func (n *StreamNotifier) Notify(ev Event) error {
+	if n.IsJSON {
+		bytes, err := json.Marshal(ev.ToJSONResponse())
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(n.Writer, string(bytes))
+		return err
+	}
+	_, err := fmt.Fprintf(n.Writer, "%s\t%s\t%s\t%s\n", ev.At.Format(time.RFC3339), ev.Type, ev.ZoneID, ev.Summary())
+	return err
+}
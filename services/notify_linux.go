@@ -0,0 +1,29 @@
+package services
+
+import (
+	"github.com/godbus/dbus/v5"
+)
+
+// DesktopNotifier shows freedesktop notifications over the D-Bus session bus
+type DesktopNotifier struct {
+	conn *dbus.Conn
+}
+
+func NewDesktopNotifier() (*DesktopNotifier, error) {
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, err
+	}
+	return &DesktopNotifier{conn: conn}, nil
+}
+
+func (n *DesktopNotifier) Notify(ev Event) error {
+	urgency := byte(1)
+	if ev.Type == EventPrayer {
+		urgency = 2
+	}
+	obj := n.conn.Object("org.freedesktop.Notifications", "/org/freedesktop/Notifications")
+	return obj.Call("org.freedesktop.Notifications.Notify", 0,
+		"Waktu Solat", uint32(0), "appointment-soon", ev.Summary(), ev.Body(),
+		[]string{}, map[string]dbus.Variant{"urgency": dbus.MakeVariant(urgency)}, int32(-1)).Err
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package services
+
+import "fmt"
+
+// DesktopNotifier is only available on Linux
+type DesktopNotifier struct{}
+
+func NewDesktopNotifier() (*DesktopNotifier, error) {
+	return nil, fmt.Errorf("desktop notifications are only supported on linux")
+}
+
+func (n *DesktopNotifier) Notify(ev Event) error {
+	return nil
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"fmt"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"strings"
+	"time"
+)
+
+const OfflineProvider = "offline"
+
+func init() {
+	RegisterProvider(OfflineProvider, func(ctx *common.Ctx) Provider {
+		return &CalculationProvider{}
+	})
+}
+
+// CalculationProvider computes prayer times from each zone's reference coordinate,
+// it works without network access but may differ from JAKIM's published times by a minute or two
+type CalculationProvider struct{}
+
+func (c *CalculationProvider) Name() string {
+	return OfflineProvider
+}
+
+func (c *CalculationProvider) Zones() ([]State, error) {
+	var states []State
+	for _, s := range bundledStates {
+		state := State{ID: s.ID, Name: s.Name}
+		for _, z := range s.Zones {
+			state.Zones = append(state.Zones, Zone{ID: z.ID, Locations: z.Locations, StateID: s.ID})
+		}
+		states = append(states, state)
+	}
+	return states, nil
+}
+
+func (c *CalculationProvider) PrayerTimes(zoneId string, from time.Time, to time.Time) ([]PrayerDate, error) {
+	zoneId = strings.ToUpper(zoneId)
+	coordinate, ok := ZoneCoordinate(zoneId)
+	if !ok {
+		return nil, fmt.Errorf("no coordinate known for zone [%s]", zoneId)
+	}
+	res := calculateRange(coordinate, from, to)
+	for i := range res {
+		res[i].ZoneID = zoneId
+	}
+	return res, nil
+}
+
+// GetPrayerTimesAt computes prayer times for an arbitrary coordinate without using the cache
+func GetPrayerTimesAt(ctx *common.Ctx, lat float64, lon float64, from time.Time, to time.Time) ([]PrayerDate, error) {
+	if err := ValidateCoordinate(lat, lon); err != nil {
+		return nil, err
+	}
+	zone := &Zone{Locations: fmt.Sprintf("%.4f, %.4f", lat, lon)}
+	res := calculateRange(Coordinate{lat, lon}, from, to)
+	opts := newDisplayOptions(ctx, "")
+	for i := range res {
+		res[i].Zone = zone
+		res[i].Calculated = true
+		res[i].init(opts)
+	}
+	return res, nil
+}
+
+func calculateRange(coordinate Coordinate, from time.Time, to time.Time) []PrayerDate {
+	var res []PrayerDate
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, JakimLocation)
+	to = time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, JakimLocation)
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		res = append(res, CalculatePrayerDate(coordinate.Latitude, coordinate.Longitude, d))
+	}
+	return res
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"fmt"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// offsetConfigPrefix starts the database keys of offsets, OFFSET_<PRAYER> or OFFSET_<ZONE>_<PRAYER>
+	offsetConfigPrefix = "OFFSET_"
+	// offsetSettingPrefix starts the config settings of offsets, offset.<prayer> or offset.<zone>.<prayer>
+	offsetSettingPrefix = "offset."
+	// MaxOffsetMinutes bounds offsets either way, larger ones are most likely typos
+	MaxOffsetMinutes = 60
+)
+
+// Offsets are per prayer adjustments applied on top of the official times, keyed by prayer key
+type Offsets map[string]time.Duration
+
+// OffsetSetting is a stored offset, ZoneID is empty for offsets applied to every zone
+type OffsetSetting struct {
+	ZoneID  string `json:"zone_id,omitempty"`
+	Prayer  string `json:"prayer"`
+	Minutes int    `json:"minutes"`
+}
+
+// PrayerKeys lists the prayer keys in the order of the day
+func PrayerKeys() []string {
+	var keys []string
+	t := reflect.TypeOf(PrayerDate{})
+	for i := 0; i < t.NumField(); i++ {
+		if v, _ := common.FindTagValue(t.Field(i).Tag, "ptMode"); v == "1" {
+			keys = append(keys, t.Field(i).Name)
+		}
+	}
+	return keys
+}
+
+// NormalizePrayerKey returns the canonical form of a prayer key given in any case
+func NormalizePrayerKey(key string) (string, error) {
+	for _, k := range PrayerKeys() {
+		if strings.EqualFold(k, key) {
+			return k, nil
+		}
+	}
+	return "", fmt.Errorf("unknown prayer [%s], expected one of %s", key, strings.Join(PrayerKeys(), "|"))
+}
+
+func offsetConfigKey(zoneId string, key string) string {
+	if len(zoneId) == 0 {
+		return offsetConfigPrefix + strings.ToUpper(key)
+	}
+	return fmt.Sprintf("%s%s_%s", offsetConfigPrefix, strings.ToUpper(zoneId), strings.ToUpper(key))
+}
+
+// parseOffsetConfigKey reads the zone and prayer of an offset stored in the database
+func parseOffsetConfigKey(id string) (string, string, bool) {
+	if !strings.HasPrefix(id, offsetConfigPrefix) {
+		return "", "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(id, offsetConfigPrefix), "_")
+	if len(parts) > 2 {
+		return "", "", false
+	}
+	key, err := NormalizePrayerKey(parts[len(parts)-1])
+	if err != nil {
+		return "", "", false
+	}
+	return strings.ToUpper(strings.Join(parts[:len(parts)-1], "")), key, true
+}
+
+// offsetSettingName is the config setting of an offset, offset.<prayer> or offset.<zone>.<prayer>
+func offsetSettingName(zoneId string, key string) string {
+	if len(zoneId) == 0 {
+		return offsetSettingPrefix + strings.ToLower(key)
+	}
+	return fmt.Sprintf("%s%s.%s", offsetSettingPrefix, strings.ToLower(zoneId), strings.ToLower(key))
+}
+
+func parseOffsetSettingName(name string) (string, string, bool) {
+	if !strings.HasPrefix(name, offsetSettingPrefix) {
+		return "", "", false
+	}
+	parts := strings.Split(strings.TrimPrefix(name, offsetSettingPrefix), ".")
+	if len(parts) > 2 {
+		return "", "", false
+	}
+	key, err := NormalizePrayerKey(parts[len(parts)-1])
+	if err != nil {
+		return "", "", false
+	}
+	return strings.ToUpper(strings.Join(parts[:len(parts)-1], "")), key, true
+}
+
+// GetOffsets returns the offsets applying to a zone, zone offsets take precedence over global ones
+func GetOffsets(ctx *common.Ctx, zoneId string) Offsets {
+	offsets := Offsets{}
+	settings := GetOffsetSettings(ctx)
+	for _, global := range []bool{true, false} {
+		for _, s := range settings {
+			if global == (len(s.ZoneID) == 0) && (global || s.ZoneID == strings.ToUpper(zoneId)) {
+				offsets[s.Prayer] = time.Duration(clampOffset(s.Minutes)) * time.Minute
+			}
+		}
+	}
+	return offsets
+}
+
+// clampOffset bounds offsets edited by hand in the config file or stored before they were checked
+func clampOffset(minutes int) int {
+	if minutes > MaxOffsetMinutes {
+		return MaxOffsetMinutes
+	}
+	if minutes < -MaxOffsetMinutes {
+		return -MaxOffsetMinutes
+	}
+	return minutes
+}
+
+// GetOffsetSettings lists every offset of the selected profile, the config file and the database
+func GetOffsetSettings(ctx *common.Ctx) []OffsetSetting {
+	values, err := ListConfig(ctx, ctx.Config.Profile)
+	if err != nil {
+		return nil
+	}
+	var res []OffsetSetting
+	for _, v := range values {
+		zoneId, key, ok := parseOffsetSettingName(v.Name)
+		if !ok {
+			continue
+		}
+		minutes, err := strconv.Atoi(v.Value)
+		if err != nil {
+			continue
+		}
+		res = append(res, OffsetSetting{ZoneID: zoneId, Prayer: key, Minutes: minutes})
+	}
+	return res
+}
+
+// SetOffset stores an offset in minutes for a prayer in the selected profile, for every zone when
+// zoneId is empty
+func SetOffset(ctx *common.Ctx, zoneId string, key string, minutes int) error {
+	key, err := NormalizePrayerKey(key)
+	if err != nil {
+		return err
+	}
+	_, err = SetConfig(ctx, ctx.Config.Profile, offsetSettingName(zoneId, key), strconv.Itoa(minutes))
+	return err
+}
+
+// UnsetOffset removes an offset from the selected profile
+func UnsetOffset(ctx *common.Ctx, zoneId string, key string) error {
+	key, err := NormalizePrayerKey(key)
+	if err != nil {
+		return err
+	}
+	return UnsetConfig(ctx, ctx.Config.Profile, offsetSettingName(zoneId, key))
+}
+
+// FormatOffset describes an adjustment, e.g. "+5 min"
+func FormatOffset(d time.Duration) string {
+	return common.T("%+d min", int(d/time.Minute))
+}
+
+// PrayerName is the name of a prayer key in the current language
+func PrayerName(key string) string {
+	return common.T(key)
+}
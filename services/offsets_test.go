@@ -0,0 +1,88 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetOffsets(t *testing.T) {
+	ctx, _ := newTestCtx(t)
+	writeConfigFile(t, ctx.Config.ConfigPath, `
+[offset]
+subuh = 2
+isyak = 5
+
+[offset.wly01]
+isyak = 10
+maghrib = 500
+
+[offset.sgr01]
+zohor = -3
+`)
+	// Offsets stored in the database before the config file still apply, the file overrides them
+	SetUserConfig(ctx, "OFFSET_IMSAK", "-90")
+	SetUserConfig(ctx, "OFFSET_SUBUH", "4")
+
+	tests := []struct {
+		zoneId string
+		want   Offsets
+	}{
+		{"wly01", Offsets{"Imsak": -time.Hour, "Subuh": 2 * time.Minute, "Isyak": 10 * time.Minute, "Maghrib": time.Hour}},
+		{"SGR01", Offsets{"Imsak": -time.Hour, "Subuh": 2 * time.Minute, "Isyak": 5 * time.Minute, "Zohor": -3 * time.Minute}},
+	}
+	for _, tt := range tests {
+		got := GetOffsets(ctx, tt.zoneId)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.zoneId, got, tt.want)
+			continue
+		}
+		for key, want := range tt.want {
+			if got[key] != want {
+				t.Errorf("%s %s: got %s, want %s", tt.zoneId, key, got[key], want)
+			}
+		}
+	}
+
+	day := date(t, "2024-03-12")
+	res := GetPrayerTimes(ctx, "WLY01", day, day)
+	if len(res) != 1 {
+		t.Fatalf("got %d days, want 1", len(res))
+	}
+	applied := map[string]struct{ display, official string }{
+		"Imsak":   {"04:50", "05:50"},
+		"Subuh":   {"06:02", "06:00"},
+		"Zohor":   {"13:15", "13:15"},
+		"Maghrib": {"20:20", "19:20"},
+		"Isyak":   {"20:40", "20:30"},
+	}
+	for _, pt := range res[0].Times {
+		want, ok := applied[pt.Key]
+		if !ok {
+			continue
+		}
+		official := pt.OfficialTime().Format(StoredTimeLayout)
+		if pt.DisplayValue != want.display || official != want.official {
+			t.Errorf("%s: got %s from %s, want %s from %s", pt.Key, pt.DisplayValue, official, want.display, want.official)
+		}
+		if pt.IsAdjusted() != (want.display != want.official) {
+			t.Errorf("%s: adjusted %v with offset %s", pt.Key, pt.IsAdjusted(), pt.Offset)
+		}
+	}
+}
+
+func TestSetOffsetRange(t *testing.T) {
+	ctx, _ := newTestCtx(t)
+	for _, minutes := range []int{-MaxOffsetMinutes, 0, MaxOffsetMinutes} {
+		if err := SetOffset(ctx, "", "Isyak", minutes); err != nil {
+			t.Errorf("%d: %s", minutes, err)
+		}
+	}
+	for _, minutes := range []int{-MaxOffsetMinutes - 1, MaxOffsetMinutes + 1, 500} {
+		if err := SetOffset(ctx, "", "Isyak", minutes); err == nil {
+			t.Errorf("%d stored without an error", minutes)
+		}
+	}
+	if got := GetOffsets(ctx, "WLY01")["Isyak"]; got != time.Hour {
+		t.Errorf("got %s, want the last valid offset of an hour", got)
+	}
+}
@@ -3,30 +3,80 @@ package services
 import (
 	"encoding/json"
 	"fmt"
-	"github.com/gocolly/colly"
 	"github.com/sayuthisobri/waktu-solat/common"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"log"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	URL               = "https://www.e-solat.gov.my/index.php?r=esolatApi/takwimsolat&period=year&zone=%s"
+	InputDateLayout   = "2006-01-02"
 	PrimaryDateLayout = "02/01/2006"
-	//DisplayTimeLayout = "03:04PM"
+	idDateLayout      = "20060102"
+)
+
+const (
+	ModeDaily   = "daily"
+	ModeWeekly  = "weekly"
+	ModeMonthly = "monthly"
+	ModeYearly  = "yearly"
 )
 
 type PrayTime struct {
-	Key          string
-	Time         time.Time
+	Key  string
+	Time time.Time
+	// DisplayValue is Time formatted with the display layout, it is never parsed back
 	DisplayValue string
 	Duration     time.Duration
 	IsCurrent    bool
+	// Offset is the user adjustment included in Time, zero for the official time
+	Offset time.Duration
+}
+
+// Name is the prayer name in the current language
+func (pt *PrayTime) Name() string {
+	return PrayerName(pt.Key)
+}
+
+// IsAdjusted reports whether the time differs from the official one
+func (pt *PrayTime) IsAdjusted() bool {
+	return pt.Offset != 0
+}
+
+// OfficialTime is the time before any user adjustment
+func (pt *PrayTime) OfficialTime() time.Time {
+	return pt.Time.Add(-pt.Offset)
+}
+
+// AdjustmentNote marks an adjusted time, e.g. " (+5 min)", empty for the official time
+func (pt *PrayTime) AdjustmentNote() string {
+	if !pt.IsAdjusted() {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", FormatOffset(pt.Offset))
+}
+
+// String is the name and time of the prayer with its adjustment, what {{ .Next }} prints in templates
+func (pt *PrayTime) String() string {
+	return fmt.Sprintf("%s %s%s", pt.Name(), pt.DisplayValue, pt.AdjustmentNote())
+}
+
+func (pt *PrayTime) ToJSONResponse() PrayTimeResponse {
+	res := PrayTimeResponse{Key: pt.Key, Name: pt.Name(), Time: pt.Time, Display: pt.DisplayValue, IsCurrent: pt.IsCurrent}
+	if pt.IsAdjusted() {
+		res.OfficialTime = common.Ptr(pt.OfficialTime())
+		res.OffsetMinutes = int(pt.Offset / time.Minute)
+	}
+	return res
 }
 
+// PrayerDate is one day of cached times, the time fields are stored in the 24 hour StoredTimeLayout
+// (toFormat) and the display layout is only applied by init
 type PrayerDate struct {
 	ID        string
 	CreatedAt time.Time
@@ -34,17 +84,19 @@ type PrayerDate struct {
 	DeletedAt gorm.DeletedAt `gorm:"index"`
 	Hijri     string         `json:"hijri" ptMode:"-"`
 	Date      string         `json:"date" fromFormat:"02-Jan-2006" toFormat:"02/01/2006" ptMode:"-"`
-	Imsak     string         `json:"imsak" fromFormat:"15:04:05" toFormat:"03:04PM" ptMode:"1"`
-	Subuh     string         `json:"fajr" fromFormat:"15:04:05" toFormat:"03:04PM" ptMode:"1"`
-	Syuruk    string         `json:"syuruk" fromFormat:"15:04:05" toFormat:"03:04PM" ptMode:"1"`
-	Zohor     string         `json:"dhuhr" fromFormat:"15:04:05" toFormat:"03:04PM" ptMode:"1"`
-	Asar      string         `json:"asr" fromFormat:"15:04:05" toFormat:"03:04PM" ptMode:"1"`
-	Maghrib   string         `json:"maghrib" fromFormat:"15:04:05" toFormat:"03:04PM" ptMode:"1"`
-	Isyak     string         `json:"isha" fromFormat:"15:04:05" toFormat:"03:04PM" ptMode:"1"`
+	Imsak     string         `json:"imsak" fromFormat:"15:04:05" toFormat:"15:04" ptMode:"1"`
+	Subuh     string         `json:"fajr" fromFormat:"15:04:05" toFormat:"15:04" ptMode:"1"`
+	Syuruk    string         `json:"syuruk" fromFormat:"15:04:05" toFormat:"15:04" ptMode:"1"`
+	Zohor     string         `json:"dhuhr" fromFormat:"15:04:05" toFormat:"15:04" ptMode:"1"`
+	Asar      string         `json:"asr" fromFormat:"15:04:05" toFormat:"15:04" ptMode:"1"`
+	Maghrib   string         `json:"maghrib" fromFormat:"15:04:05" toFormat:"15:04" ptMode:"1"`
+	Isyak     string         `json:"isha" fromFormat:"15:04:05" toFormat:"15:04" ptMode:"1"`
 	ZoneID    string         `ptMode:"-"`
 	Zone      *Zone
 
 	Times []PrayTime `gorm:"-:all"`
+	// Calculated is set when the times come from the offline calculation instead of JAKIM
+	Calculated bool `gorm:"-:all" ptMode:"-"`
 }
 
 func (p *PrayerDate) UnmarshalJSON(bytes []byte) error {
@@ -54,7 +106,7 @@ func (p *PrayerDate) UnmarshalJSON(bytes []byte) error {
 	convertTime := func(t reflect.StructTag, v string) (time.Time, error) {
 		f, _ := common.FindTagValue(t, "fromFormat")
 		if f != "" {
-			return time.ParseInLocation(f, v, time.Local)
+			return time.ParseInLocation(f, v, JakimLocation)
 		}
 		return time.Time{}, fmt.Errorf("date format not found")
 	}
@@ -80,18 +132,29 @@ func (p *PrayerDate) UnmarshalJSON(bytes []byte) error {
 	return nil
 }
 
+// CalculatedNote warns that the times are calculated, empty for JAKIM's times
+func (p *PrayerDate) CalculatedNote() string {
+	if !p.Calculated {
+		return ""
+	}
+	return common.T("Calculated times, they may differ from JAKIM's by a minute or two")
+}
+
 func (p *PrayerDate) ToAlfredResponse() common.AlfredResponse {
 	var items []common.AlfredResponseItem
 	var vars = make(map[string]string)
 	vars["location"] = p.Zone.Locations
 	for _, pt := range p.Times {
-		val := pt.DisplayValue
+		val := pt.DisplayValue + pt.AdjustmentNote()
 		if pt.IsCurrent {
-			val = fmt.Sprintf("%s | Current", pt.DisplayValue)
+			val = fmt.Sprintf("%s | %s", val, common.T("Current"))
 		} else if pt.Duration > 0 {
-			val = fmt.Sprintf("%s | In %s", pt.DisplayValue, common.Timespan(pt.Time.Sub(time.Now()).Round(time.Second)).Format())
+			val = fmt.Sprintf("%s | %s", val, common.T("In %s", common.Timespan(pt.Time.Sub(time.Now()).Round(time.Second)).Format()))
 		}
-		subtitle := fmt.Sprintf("Change Zone | %s", p.Zone.Locations)
+		if p.Calculated {
+			val = fmt.Sprintf("%s | %s", val, common.T("calculated"))
+		}
+		subtitle := common.T("Change Zone | %s", p.Zone.Locations)
 		mods := map[string]*common.Modifier{
 			"cmd": {
 				Subtitle: &subtitle,
@@ -102,7 +165,7 @@ func (p *PrayerDate) ToAlfredResponse() common.AlfredResponse {
 			},
 		}
 		item := common.AlfredResponseItem{
-			Title:    pt.Key,
+			Title:    pt.Name(),
 			Subtitle: &val,
 			Valid:    true,
 			Mods:     mods,
@@ -117,11 +180,126 @@ func (p *PrayerDate) ToAlfredResponse() common.AlfredResponse {
 	}
 }
 
-func (p *PrayerDate) init() {
+type PrayerDates []PrayerDate
+
+func (ps *PrayerDates) ToAlfredResponse() common.AlfredResponse {
+	dates := []PrayerDate(*ps)
+	var items []common.AlfredResponseItem
+	var vars = make(map[string]string)
+	today := Today().Format(PrimaryDateLayout)
+	for _, p := range dates {
+		if p.Zone != nil {
+			vars["location"] = p.Zone.Locations
+		}
+		var times []string
+		for _, pt := range p.Times {
+			times = append(times, fmt.Sprintf("%s %s%s", pt.Name(), pt.DisplayValue, pt.AdjustmentNote()))
+		}
+		title := fmt.Sprintf("%s | %s", p.Date, p.HijriDisplay())
+		if p.Date == today {
+			title = fmt.Sprintf("%s | %s", title, common.T("Today"))
+		}
+		if p.Calculated {
+			title = fmt.Sprintf("%s | %s", title, common.T("calculated"))
+		}
+		subtitle := strings.Join(times, "  ")
+		match := p.Date
+		items = append(items, common.AlfredResponseItem{
+			Title:    title,
+			Subtitle: &subtitle,
+			Match:    &match,
+			Arg:      p.Date,
+			Valid:    true,
+		})
+	}
+	return common.AlfredResponse{
+		Variables: vars,
+		Items:     items,
+	}
+}
+
+type PrayTimeResponse struct {
+	Key       string    `json:"key"`
+	Name      string    `json:"name"`
+	Time      time.Time `json:"time"`
+	Display   string    `json:"display"`
+	IsCurrent bool      `json:"is_current"`
+	// OfficialTime and OffsetMinutes are only set when a user offset was applied
+	OfficialTime  *time.Time `json:"official_time,omitempty"`
+	OffsetMinutes int        `json:"offset_minutes,omitempty"`
+}
+
+type PrayerDateResponse struct {
+	Date             string             `json:"date"`
+	Hijri            string             `json:"hijri"`
+	Zone             *ZoneResponse      `json:"zone,omitempty"`
+	Times            []PrayTimeResponse `json:"times"`
+	Current          *PrayTimeResponse  `json:"current,omitempty"`
+	Next             *PrayTimeResponse  `json:"next,omitempty"`
+	SecondsRemaining *int64             `json:"seconds_remaining,omitempty"`
+	// Calculated is set when the times come from the offline calculation instead of JAKIM
+	Calculated bool `json:"calculated"`
+}
+
+func (p *PrayerDate) ToJSONResponse() PrayerDateResponse {
+	res := PrayerDateResponse{
+		Date:       p.Date,
+		Hijri:      p.Hijri,
+		Times:      []PrayTimeResponse{},
+		Calculated: p.Calculated,
+	}
+	if date, err := time.ParseInLocation(PrimaryDateLayout, p.Date, JakimLocation); err == nil {
+		res.Date = date.Format(InputDateLayout)
+	}
+	if p.Zone != nil {
+		zone := p.Zone.ToJSONResponse()
+		res.Zone = &zone
+	}
+	for _, pt := range p.Times {
+		t := pt.ToJSONResponse()
+		res.Times = append(res.Times, t)
+		if pt.IsCurrent {
+			res.Current = &t
+		} else if pt.Duration > 0 && res.Next == nil {
+			res.Next = &t
+			seconds := int64(pt.Duration / time.Second)
+			res.SecondsRemaining = &seconds
+		}
+	}
+	return res
+}
+
+func (ps *PrayerDates) ToJSONResponse() []PrayerDateResponse {
+	res := []PrayerDateResponse{}
+	for _, p := range *ps {
+		res = append(res, p.ToJSONResponse())
+	}
+	return res
+}
+
+// displayOptions control how init turns the stored time strings into PrayTime values
+type displayOptions struct {
+	offsets  Offsets
+	layout   string
+	location *time.Location
+}
+
+func newDisplayOptions(ctx *common.Ctx, zoneId string) displayOptions {
+	return displayOptions{
+		offsets:  GetOffsets(ctx, zoneId),
+		layout:   DisplayTimeLayout(ctx),
+		location: DisplayLocation(ctx),
+	}
+}
+
+// init builds Times from the stored Malaysian time strings, applying offsets and converting them for display
+func (p *PrayerDate) init(opts displayOptions) {
 	rp := reflect.ValueOf(p).Elem()
 	dateField := rp.FieldByName("Date")
 	dateStr := dateField.String()
 	currentIndex := -1
+	// Only today's row has a current prayer, other days in a range are either all past or all upcoming
+	isToday := dateStr == Today().Format(PrimaryDateLayout)
 	p.Times = nil
 	for i := rp.NumField() - 1; i > -1; i-- {
 		typeField := rp.Type().Field(i)
@@ -131,17 +309,20 @@ func (p *PrayerDate) init() {
 		timeStr := field.String()
 		key := typeField.Name
 		if tagValue == "1" {
-			pTime, _ := time.ParseInLocation(fmt.Sprintf("%s 03:04PM", PrimaryDateLayout), fmt.Sprintf("%s %s", dateStr, timeStr), time.Local)
+			pTime, _ := time.ParseInLocation(fmt.Sprintf("%s %s", PrimaryDateLayout, StoredTimeLayout), fmt.Sprintf("%s %s", dateStr, timeStr), JakimLocation)
+			offset := opts.offsets[key]
+			pTime = pTime.Add(offset).In(opts.location)
 			duration := pTime.Sub(time.Now()).Round(time.Second)
-			if duration < 0 && currentIndex == -1 {
+			if duration < 0 && currentIndex == -1 && isToday {
 				currentIndex = i
 			}
 			p.Times = append(p.Times, PrayTime{
 				Key:          key,
 				Time:         pTime,
-				DisplayValue: timeStr,
+				DisplayValue: pTime.Format(opts.layout),
 				Duration:     duration,
 				IsCurrent:    currentIndex == i,
+				Offset:       offset,
 			})
 		}
 	}
@@ -153,71 +334,194 @@ type PrayerTimesDto struct {
 	PrayerTimes []PrayerDate `json:"prayerTime"`
 }
 
-func GetPrayerTimes(ctx *common.Ctx, zoneId string, mode string) []PrayerDate {
+func GetPrayerTimes(ctx *common.Ctx, zoneId string, from time.Time, to time.Time) []PrayerDate {
 	if len(zoneId) == 0 {
-		zoneId = GetUserConfig(ctx, "ZONE_ID", "WLY01")
+		zoneId = GetConfig(ctx, ConfigZone)
 	}
 	zoneId = strings.ToUpper(zoneId)
-	db, _ := OpenDb(ctx)
-	prayerDate := &PrayerDate{}
+	db, err := OpenDb(ctx)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
 	zone := getZone(ctx, zoneId)
-	recordCount := int64(0)
-	db.Model(prayerDate).Where("zone_id = ?", zoneId).Count(&recordCount)
-	todayDate := time.Now().Format(PrimaryDateLayout)
-	var resDto *PrayerTimesDto
-	var res []PrayerDate
-	if recordCount == 0 {
-		resDto = fetchData(zoneId, zone, db)
-		//for _, p := range resDto.PrayerTimes {
-		//	if p.Date == todayDate {
-		//		p.init()
-		//		res = append(res, p)
-		//	}
-		//}
+	provider, err := GetProvider(ctx)
+	if err != nil {
+		log.Println(err)
+		return nil
 	}
-	switch mode {
-	case "daily":
-		if resDto != nil && len(resDto.PrayerTimes) != 0 {
-			for _, p := range resDto.PrayerTimes {
-				if p.Date == todayDate {
-					p.init()
-					res = append(res, p)
-					return res
-				}
+	years := make([]int, 0, to.Year()-from.Year()+2)
+	for year := from.Year(); year <= to.Year(); year++ {
+		years = append(years, year)
+	}
+	if next := Today().Year() + 1; next > to.Year() && isPrefetchDue(time.Now()) {
+		years = append(years, next)
+	}
+	backgroundUpdate := false
+	fallback := false
+	calculatedYears := map[string]bool{}
+	for _, year := range years {
+		record := getFetchRecord(db, zoneId, year)
+		if isFetchDue(record, provider, time.Now()) {
+			if ctx.Config.IsAlfred() && record != nil && record.Count > 0 {
+				// Cached times are still usable, refresh them without blocking the script filter
+				backgroundUpdate = true
+			} else {
+				record, _, _ = refreshYear(db, provider, zoneId, zone, year)
 			}
 		}
-		tx := db.Joins("Zone").First(prayerDate, "zone_id = ? AND date = ?",
-			zoneId, todayDate)
-		if tx.Error == nil && tx.RowsAffected > 0 {
-			prayerDate.init()
-			return []PrayerDate{*prayerDate}
+		// Only the requested years fall back on calculated times, never the prefetched one
+		if record != nil && record.Count == 0 && record.Unreachable && year <= to.Year() && provider.Name() != OfflineProvider {
+			fallback = true
+		}
+		if record != nil && record.Count > 0 && record.Provider == OfflineProvider {
+			calculatedYears[strconv.Itoa(year)] = true
 		}
 	}
-	return nil
+	if backgroundUpdate {
+		_ = common.BackgroundUpdate(append(globalArgs(ctx, provider), "update", zoneId)...)
+	}
+	var res []PrayerDate
+	tx := db.Joins("Zone").
+		Where("prayer_dates.zone_id = ? AND prayer_dates.id BETWEEN ? AND ?",
+			zoneId, prayerDateId(from, zoneId), prayerDateId(to, zoneId)).
+		Order("prayer_dates.id").
+		Find(&res)
+	if tx.Error != nil {
+		return nil
+	}
+	for i := range res {
+		res[i].Calculated = calculatedYears[res[i].ID[:4]]
+	}
+	if fallback {
+		res = withCalculatedTimes(res, zoneId, zone, from, to)
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	opts := newDisplayOptions(ctx, zoneId)
+	for i := range res {
+		res[i].init(opts)
+	}
+	return res
 }
 
-func fetchData(zoneId string, zone *Zone, db *gorm.DB) *PrayerTimesDto {
-	c := colly.NewCollector()
-	var resDto = &PrayerTimesDto{}
-	c.OnResponse(func(r *colly.Response) {
-		_ = json.Unmarshal(r.Body, resDto)
-		for i := range resDto.PrayerTimes {
-			t := &resDto.PrayerTimes[i]
-			handleDateToId := func(from string) string {
-				t, err := time.ParseInLocation("02/01/2006", from, time.Local)
-				if err != nil {
-					return strings.ReplaceAll(from, "/", "")
-				}
-				return t.Format("20060102")
-			}
-			t.ID = fmt.Sprintf("%s-%s", handleDateToId(t.Date), zoneId)
-			t.ZoneID = zoneId
-			t.Zone = zone
+// globalArgs are the global flags that make a background run use the same database, config
+// file, profile and provider
+func globalArgs(ctx *common.Ctx, provider Provider) []string {
+	args := []string{"--db", ctx.Config.DbPath, "--provider", provider.Name()}
+	if len(ctx.Config.ConfigPath) != 0 {
+		args = append(args, "--config", ctx.Config.ConfigPath)
+	}
+	if len(ctx.Config.Profile) != 0 {
+		args = append(args, "--profile", ctx.Config.Profile)
+	}
+	return args
+}
+
+// withCalculatedTimes fills the days missing from res with calculated times, they are not stored so
+// the official times are fetched as soon as the provider can be reached again
+func withCalculatedTimes(res []PrayerDate, zoneId string, zone *Zone, from time.Time, to time.Time) []PrayerDate {
+	calculated, err := (&CalculationProvider{}).PrayerTimes(zoneId, from, to)
+	if err != nil {
+		return res
+	}
+	log.Printf("Using calculated prayer times for %s, the official times could not be fetched", zoneId)
+	cached := map[string]bool{}
+	for _, p := range res {
+		cached[p.ID] = true
+	}
+	for _, p := range calculated {
+		if date, err := time.ParseInLocation(PrimaryDateLayout, p.Date, JakimLocation); err == nil {
+			p.ID = prayerDateId(date, zoneId)
 		}
-		updatePrayerTime(&resDto.PrayerTimes, db)
+		if cached[p.ID] {
+			continue
+		}
+		p.Zone = zone
+		p.Calculated = true
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].ID < res[j].ID
 	})
-	_ = c.Visit(fmt.Sprintf(URL, strings.ToUpper(zoneId)))
-	return resDto
+	return res
+}
+
+// ParseDate parses a date given by the user, either as YYYY-MM-DD, DD/MM/YYYY or one of
+// today, tomorrow and yesterday, dates are midnight in Malaysia
+func ParseDate(value string) (time.Time, error) {
+	today := Today()
+	switch strings.ToLower(value) {
+	case "", "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	for _, layout := range []string{InputDateLayout, PrimaryDateLayout} {
+		if t, err := time.ParseInLocation(layout, value, JakimLocation); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date [%s], expected YYYY-MM-DD or DD/MM/YYYY", value)
+}
+
+// ModeRange returns the first and last day (inclusive) covered by mode around ref.
+// Weeks start on Monday.
+func ModeRange(mode string, ref time.Time) (time.Time, time.Time, bool) {
+	day := time.Date(ref.Year(), ref.Month(), ref.Day(), 0, 0, 0, 0, ref.Location())
+	switch mode {
+	case ModeDaily:
+		return day, day, true
+	case ModeWeekly:
+		from := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+		return from, from.AddDate(0, 0, 6), true
+	case ModeMonthly:
+		from := day.AddDate(0, 0, 1-day.Day())
+		return from, from.AddDate(0, 1, -1), true
+	case ModeYearly:
+		from := time.Date(day.Year(), time.January, 1, 0, 0, 0, 0, day.Location())
+		return from, from.AddDate(1, 0, -1), true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// DateRange resolves a reference date and mode into an inclusive range of days, a custom range
+// overrides the mode when from or to is given, to defaults to from and from to the reference date
+func DateRange(date string, mode string, from string, to string) (time.Time, time.Time, error) {
+	ref, err := ParseDate(date)
+	if err != nil {
+		return ref, ref, err
+	}
+	start, end := ref, ref
+	if len(from) != 0 || len(to) != 0 {
+		if len(from) != 0 {
+			if start, err = ParseDate(from); err != nil {
+				return start, end, err
+			}
+			end = start
+		}
+		if len(to) != 0 {
+			if end, err = ParseDate(to); err != nil {
+				return start, end, err
+			}
+		}
+	} else if len(mode) != 0 {
+		var ok bool
+		if start, end, ok = ModeRange(mode, ref); !ok {
+			return start, end, fmt.Errorf("%s", common.T("invalid mode [%s]", mode))
+		}
+	}
+	if end.Before(start) {
+		return start, end, fmt.Errorf("%s", common.T("to date must not be before from date"))
+	}
+	return start, end, nil
+}
+
+func prayerDateId(date time.Time, zoneId string) string {
+	return fmt.Sprintf("%s-%s", date.Format(idDateLayout), zoneId)
 }
 
 //func parseTime(date string, timeStr string) time.Time {
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestModeRange(t *testing.T) {
+	tests := []struct {
+		mode string
+		ref  string
+		from string
+		to   string
+	}{
+		{ModeDaily, "2024-03-13", "2024-03-13", "2024-03-13"},
+		{ModeWeekly, "2024-03-13", "2024-03-11", "2024-03-17"},
+		{ModeWeekly, "2024-03-11", "2024-03-11", "2024-03-17"},
+		{ModeWeekly, "2024-03-17", "2024-03-11", "2024-03-17"},
+		{ModeWeekly, "2024-12-31", "2024-12-30", "2025-01-05"},
+		{ModeMonthly, "2024-02-10", "2024-02-01", "2024-02-29"},
+		{ModeMonthly, "2023-02-10", "2023-02-01", "2023-02-28"},
+		{ModeMonthly, "2024-12-31", "2024-12-01", "2024-12-31"},
+		{ModeYearly, "2024-07-15", "2024-01-01", "2024-12-31"},
+	}
+	for _, tt := range tests {
+		from, to, ok := ModeRange(tt.mode, date(t, tt.ref))
+		if !ok {
+			t.Errorf("%s %s: mode not accepted", tt.mode, tt.ref)
+			continue
+		}
+		if got, want := from.Format(InputDateLayout)+" "+to.Format(InputDateLayout), tt.from+" "+tt.to; got != want {
+			t.Errorf("%s %s: got %s, want %s", tt.mode, tt.ref, got, want)
+		}
+		if from.Location() != JakimLocation || from.Hour() != 0 || to.Hour() != 0 {
+			t.Errorf("%s %s: got %s to %s, want midnights in Malaysia", tt.mode, tt.ref, from, to)
+		}
+	}
+	if _, _, ok := ModeRange("hourly", date(t, "2024-03-13")); ok {
+		t.Error("hourly accepted as a mode")
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	today := Today()
+	tests := []struct {
+		value string
+		want  time.Time
+		err   bool
+	}{
+		{value: "2024-03-12", want: date(t, "2024-03-12")},
+		{value: "12/03/2024", want: date(t, "2024-03-12")},
+		{value: "29/02/2024", want: date(t, "2024-02-29")},
+		{value: "", want: today},
+		{value: "today", want: today},
+		{value: "Tomorrow", want: today.AddDate(0, 0, 1)},
+		{value: "YESTERDAY", want: today.AddDate(0, 0, -1)},
+		{value: "2024-13-01", err: true},
+		{value: "29/02/2023", err: true},
+		{value: "12-03-2024", err: true},
+		{value: "next week", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDate(tt.value)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: got %s, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", tt.value, err)
+		} else if !got.Equal(tt.want) || got.Location() != JakimLocation {
+			t.Errorf("%q: got %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestDateRange(t *testing.T) {
+	tests := []struct {
+		name                 string
+		date, mode, from, to string
+		wantFrom, wantTo     string
+		err                  bool
+	}{
+		{name: "mode around date", date: "2024-03-13", mode: ModeWeekly, wantFrom: "2024-03-11", wantTo: "2024-03-17"},
+		{name: "no mode is the date", date: "2024-03-13", wantFrom: "2024-03-13", wantTo: "2024-03-13"},
+		{name: "from overrides mode", date: "2024-03-13", mode: ModeMonthly, from: "2024-05-02", wantFrom: "2024-05-02", wantTo: "2024-05-02"},
+		{name: "from and to", mode: ModeYearly, from: "2024-03-01", to: "05/03/2024", wantFrom: "2024-03-01", wantTo: "2024-03-05"},
+		{name: "to starts at date", date: "2024-03-13", mode: ModeMonthly, to: "2024-03-15", wantFrom: "2024-03-13", wantTo: "2024-03-15"},
+		{name: "range spans two years", from: "2024-12-30", to: "2025-01-02", wantFrom: "2024-12-30", wantTo: "2025-01-02"},
+		{name: "invalid mode ignored with from", mode: "hourly", from: "2024-03-01", wantFrom: "2024-03-01", wantTo: "2024-03-01"},
+		{name: "to before from", from: "2024-03-05", to: "2024-03-01", err: true},
+		{name: "to before date", date: "2024-03-13", to: "2024-03-12", err: true},
+		{name: "invalid mode", date: "2024-03-13", mode: "hourly", err: true},
+		{name: "invalid date", date: "13/13/2024", mode: ModeDaily, err: true},
+		{name: "invalid from", from: "2024-02-30", err: true},
+		{name: "invalid to", from: "2024-03-01", to: "soon", err: true},
+	}
+	for _, tt := range tests {
+		from, to, err := DateRange(tt.date, tt.mode, tt.from, tt.to)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: got %s to %s, want an error", tt.name, from, to)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if got, want := from.Format(InputDateLayout)+" "+to.Format(InputDateLayout), tt.wantFrom+" "+tt.wantTo; got != want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, want)
+		}
+	}
+}
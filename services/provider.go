@@ -0,0 +1,52 @@
+package services
+
+import (
+	"fmt"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"sort"
+	"strings"
+	"time"
+)
+
+const DefaultProvider = "esolat"
+
+// Provider is a source of zones and prayer times
+type Provider interface {
+	// Name is the identifier used to select the provider
+	Name() string
+	// Zones lists every state with its zones
+	Zones() ([]State, error)
+	// PrayerTimes returns the prayer times of a zone for each day between from and to (inclusive).
+	// Returned rows only need Date, Hijri, the prayer times and ZoneID, the store assigns the rest.
+	PrayerTimes(zoneId string, from time.Time, to time.Time) ([]PrayerDate, error)
+}
+
+var providers = map[string]func(ctx *common.Ctx) Provider{}
+
+// RegisterProvider makes a provider available to --provider under name
+func RegisterProvider(name string, factory func(ctx *common.Ctx) Provider) {
+	providers[strings.ToLower(name)] = factory
+}
+
+// ProviderNames lists the registered providers
+func ProviderNames() []string {
+	var names []string
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetProvider returns the provider selected by --provider, the provider setting or the default one
+func GetProvider(ctx *common.Ctx) (Provider, error) {
+	name := ctx.Config.Provider
+	if len(name) == 0 {
+		name = GetConfig(ctx, ConfigProvider)
+	}
+	factory, ok := providers[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("provider [%s] not found, expected one of %s", name, strings.Join(ProviderNames(), "|"))
+	}
+	return factory(ctx), nil
+}
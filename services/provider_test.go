@@ -0,0 +1,176 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeProvider serves the same times every day, in the format e-solat publishes them
+type fakeProvider struct {
+	name string
+	// err is returned instead of the times when set
+	err error
+	// times replaces some of fakeTimes when set, e.g. to revise a prayer time
+	times map[string]string
+
+	mu    sync.Mutex
+	calls map[int]int
+}
+
+var fakeTimes = map[string]string{
+	"imsak":   "05:50:00",
+	"fajr":    "06:00:00",
+	"syuruk":  "07:10:00",
+	"dhuhr":   "13:15:00",
+	"asr":     "16:35:00",
+	"maghrib": "19:20:00",
+	"isha":    "20:30:00",
+}
+
+func (f *fakeProvider) Name() string {
+	return f.name
+}
+
+func (f *fakeProvider) Zones() ([]State, error) {
+	return (&CalculationProvider{}).Zones()
+}
+
+func (f *fakeProvider) PrayerTimes(zoneId string, from time.Time, to time.Time) ([]PrayerDate, error) {
+	f.mu.Lock()
+	f.calls[from.Year()]++
+	f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	var days []map[string]string
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		row := map[string]string{"hijri": TabularHijri(day).String(), "date": day.Format("02-Jan-2006")}
+		for k, v := range fakeTimes {
+			row[k] = v
+		}
+		for k, v := range f.times {
+			row[k] = v
+		}
+		days = append(days, row)
+	}
+	body, err := json.Marshal(map[string]any{"prayerTime": days})
+	if err != nil {
+		return nil, err
+	}
+	var dto PrayerTimesDto
+	if err = json.Unmarshal(body, &dto); err != nil {
+		return nil, err
+	}
+	for i := range dto.PrayerTimes {
+		dto.PrayerTimes[i].ZoneID = zoneId
+	}
+	return dto.PrayerTimes, nil
+}
+
+func (f *fakeProvider) fetches(year int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[year]
+}
+
+// newTestCtx uses a fresh database and config file with a fake provider
+func newTestCtx(t *testing.T) (*common.Ctx, *fakeProvider) {
+	t.Helper()
+	dir := t.TempDir()
+	provider := &fakeProvider{name: fmt.Sprintf("fake-%s", t.Name()), calls: map[int]int{}}
+	RegisterProvider(provider.name, func(ctx *common.Ctx) Provider {
+		return provider
+	})
+	t.Cleanup(func() {
+		delete(providers, provider.name)
+	})
+	ctx := &common.Ctx{Config: &common.Config{
+		DbPath:     filepath.Join(dir, "ws.db"),
+		ConfigPath: filepath.Join(dir, "config.toml"),
+		Provider:   provider.name,
+		TimeFormat: TimeFormat24h,
+	}}
+	t.Cleanup(func() {
+		CloseDb(ctx)
+	})
+	return ctx, provider
+}
+
+func date(t *testing.T, value string) time.Time {
+	t.Helper()
+	d, err := time.ParseInLocation(InputDateLayout, value, JakimLocation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestGetPrayerTimesCachesProviderTimes(t *testing.T) {
+	ctx, provider := newTestCtx(t)
+	from, to := date(t, "2024-03-11"), date(t, "2024-03-13")
+	res := GetPrayerTimes(ctx, "wly01", from, to)
+	if len(res) != 3 {
+		t.Fatalf("got %d days, want 3", len(res))
+	}
+	for _, p := range res {
+		if p.Calculated {
+			t.Errorf("%s: times of the provider marked as calculated", p.Date)
+		}
+		if p.Zone == nil || p.Zone.ID != "WLY01" {
+			t.Errorf("%s: zone %v, want WLY01", p.Date, p.Zone)
+		}
+	}
+	first := res[0]
+	if first.Date != "11/03/2024" || first.Subuh != "06:00" || first.Isyak != "20:30" {
+		t.Errorf("got %s subuh %s isyak %s, want 11/03/2024 06:00 20:30", first.Date, first.Subuh, first.Isyak)
+	}
+	if len(first.Times) != 7 || first.Times[1].Key != "Subuh" || first.Times[1].DisplayValue != "06:00" {
+		t.Errorf("unexpected times %+v", first.Times)
+	}
+
+	GetPrayerTimes(ctx, "WLY01", from, to)
+	if n := provider.fetches(2024); n != 1 {
+		t.Errorf("provider called %d times for 2024, want 1 as the year is cached", n)
+	}
+}
+
+func TestGetPrayerTimesFallsBackWhenUnreachable(t *testing.T) {
+	ctx, provider := newTestCtx(t)
+	provider.err = &url.Error{Op: "Get", URL: "https://www.e-solat.gov.my", Err: errors.New("no such host")}
+	day := date(t, "2024-03-12")
+	res := GetPrayerTimes(ctx, "WLY01", day, day)
+	if len(res) != 1 || !res[0].Calculated {
+		t.Fatalf("got %+v, want a single calculated day", res)
+	}
+	if res[0].Date != "12/03/2024" {
+		t.Errorf("got %s, want 12/03/2024", res[0].Date)
+	}
+	db, err := OpenDb(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count int64
+	db.Model(&PrayerDate{}).Count(&count)
+	if count != 0 {
+		t.Errorf("%d calculated days stored, want none", count)
+	}
+	if record := getFetchRecord(db, "WLY01", 2024); record == nil || !record.Unreachable || record.Count != 0 {
+		t.Errorf("got fetch record %+v, want an unreachable attempt", record)
+	}
+}
+
+func TestGetPrayerTimesDoesNotFallBackOnProviderErrors(t *testing.T) {
+	ctx, provider := newTestCtx(t)
+	provider.err = errors.New("invalid response")
+	day := date(t, "2024-03-12")
+	if res := GetPrayerTimes(ctx, "WLY01", day, day); len(res) != 0 {
+		t.Errorf("got %d days, want none when the provider answered without times", len(res))
+	}
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"fmt"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"strings"
+	"time"
+)
+
+const (
+	RamadanMonth = 9
+
+	CountdownSahur = "sahur"
+	CountdownIftar = "iftar"
+)
+
+// FastingDay is one day of Ramadan with the times that matter for fasting,
+// Imsak, Subuh and Maghrib shadow the stored strings of PrayerDate
+type FastingDay struct {
+	PrayerDate
+	// Day is the day of Ramadan
+	Day     int
+	Imsak   PrayTime
+	Subuh   PrayTime
+	Maghrib PrayTime
+}
+
+// Length is how long the fast lasts, from Subuh to Maghrib
+func (d *FastingDay) Length() time.Duration {
+	return d.Maghrib.Time.Sub(d.Subuh.Time)
+}
+
+// Ramadan is a zone's fasting schedule for a Hijri year
+type Ramadan struct {
+	Year   int
+	ZoneID string
+	Zone   *Zone
+	Days   []FastingDay
+	// Source is HijriSourceJakim when the month's start and end were published, HijriSourceTabular otherwise
+	Source string
+}
+
+// Countdown is the next moment to watch for during Ramadan, iftar during the fast and sahur otherwise
+type Countdown struct {
+	Type      string
+	Prayer    PrayTime
+	Remaining time.Duration
+}
+
+// GetRamadan returns the days of Ramadan of the Hijri year for a zone
+func GetRamadan(ctx *common.Ctx, zoneId string, year int) (*Ramadan, error) {
+	start := FromHijri(ctx, zoneId, HijriDate{Year: year, Month: RamadanMonth, Day: 1})
+	end := FromHijri(ctx, zoneId, HijriDate{Year: year, Month: RamadanMonth + 1, Day: 1})
+	res := &Ramadan{Year: year, Source: HijriSourceJakim}
+	if start.Source != HijriSourceJakim || end.Source != HijriSourceJakim {
+		res.Source = HijriSourceTabular
+	}
+	prayerDates := GetPrayerTimes(ctx, zoneId, start.Date, end.Date.AddDate(0, 0, -1))
+	if len(prayerDates) == 0 {
+		return nil, fmt.Errorf("no prayer times found for Ramadan %d", year)
+	}
+	for i, p := range prayerDates {
+		day := FastingDay{PrayerDate: p, Day: i + 1}
+		if h, err := p.HijriDate(); err == nil && res.Source == HijriSourceJakim {
+			if h.Month != RamadanMonth {
+				continue
+			}
+			day.Day = h.Day
+		}
+		for _, pt := range p.Times {
+			switch pt.Key {
+			case "Imsak":
+				day.Imsak = pt
+			case "Subuh":
+				day.Subuh = pt
+			case "Maghrib":
+				day.Maghrib = pt
+			}
+		}
+		res.Days = append(res.Days, day)
+		res.ZoneID, res.Zone = p.ZoneID, p.Zone
+	}
+	return res, nil
+}
+
+// CurrentRamadanYear is the Hijri year of the Ramadan falling in the current Gregorian year
+func CurrentRamadanYear() int {
+	return HijriDate{Month: RamadanMonth, Day: 1}.InGregorianYear(Today().Year()).Year
+}
+
+// Countdown returns the next sahur or iftar after now, false outside Ramadan
+func (r *Ramadan) Countdown(now time.Time) (Countdown, bool) {
+	if r.Today() == nil {
+		return Countdown{}, false
+	}
+	for _, d := range r.Days {
+		if now.Before(d.Subuh.Time) {
+			return Countdown{Type: CountdownSahur, Prayer: d.Subuh, Remaining: d.Subuh.Time.Sub(now)}, true
+		}
+		if now.Before(d.Maghrib.Time) {
+			return Countdown{Type: CountdownIftar, Prayer: d.Maghrib, Remaining: d.Maghrib.Time.Sub(now)}, true
+		}
+	}
+	return Countdown{}, false
+}
+
+// Today returns the current day of Ramadan, nil outside Ramadan
+func (r *Ramadan) Today() *FastingDay {
+	today := Today().Format(PrimaryDateLayout)
+	for i := range r.Days {
+		if r.Days[i].Date == today {
+			return &r.Days[i]
+		}
+	}
+	return nil
+}
+
+// Describe is the countdown in the current language, e.g. "Iftar in 2hours 5min"
+func (c *Countdown) Describe() string {
+	remaining := common.Timespan(c.Remaining.Round(time.Second)).Format()
+	if c.Type == CountdownIftar {
+		return common.T("Iftar in %s", remaining)
+	}
+	return common.T("Sahur ends in %s", remaining)
+}
+
+type FastingDayResponse struct {
+	Day            int              `json:"day"`
+	Date           string           `json:"date"`
+	Hijri          string           `json:"hijri"`
+	Imsak          PrayTimeResponse `json:"imsak"`
+	Subuh          PrayTimeResponse `json:"subuh"`
+	Maghrib        PrayTimeResponse `json:"maghrib"`
+	FastingSeconds int64            `json:"fasting_seconds"`
+	IsToday        bool             `json:"is_today"`
+}
+
+type CountdownResponse struct {
+	Type             string           `json:"type"`
+	Prayer           PrayTimeResponse `json:"prayer"`
+	SecondsRemaining int64            `json:"seconds_remaining"`
+}
+
+type RamadanResponse struct {
+	Year      int                  `json:"year"`
+	Zone      *ZoneResponse        `json:"zone,omitempty"`
+	Source    string               `json:"source"`
+	Days      []FastingDayResponse `json:"days"`
+	Countdown *CountdownResponse   `json:"countdown,omitempty"`
+}
+
+func (r *Ramadan) ToJSONResponse(now time.Time) RamadanResponse {
+	res := RamadanResponse{Year: r.Year, Source: r.Source, Days: []FastingDayResponse{}}
+	if r.Zone != nil {
+		zone := r.Zone.ToJSONResponse()
+		res.Zone = &zone
+	}
+	today := Today().Format(PrimaryDateLayout)
+	for _, d := range r.Days {
+		date := d.ToJSONResponse()
+		res.Days = append(res.Days, FastingDayResponse{
+			Day:            d.Day,
+			Date:           date.Date,
+			Hijri:          d.Hijri,
+			Imsak:          d.Imsak.ToJSONResponse(),
+			Subuh:          d.Subuh.ToJSONResponse(),
+			Maghrib:        d.Maghrib.ToJSONResponse(),
+			FastingSeconds: int64(d.Length() / time.Second),
+			IsToday:        d.Date == today,
+		})
+	}
+	if c, ok := r.Countdown(now); ok {
+		res.Countdown = &CountdownResponse{
+			Type:             c.Type,
+			Prayer:           c.Prayer.ToJSONResponse(),
+			SecondsRemaining: int64(c.Remaining / time.Second),
+		}
+	}
+	return res
+}
+
+func (r *Ramadan) ToAlfredResponse(now time.Time) common.AlfredResponse {
+	res := common.AlfredResponse{}
+	if r.Zone != nil {
+		res.Variables = map[string]string{"location": r.Zone.Locations}
+	}
+	if c, ok := r.Countdown(now); ok {
+		subtitle := fmt.Sprintf("%s %s", c.Prayer.Name(), c.Prayer.DisplayValue)
+		res.AddItem(common.AlfredResponseItem{Title: c.Describe(), Subtitle: &subtitle, Valid: false})
+	}
+	today := Today().Format(PrimaryDateLayout)
+	for _, d := range r.Days {
+		title := fmt.Sprintf("%s | %s", HijriDate{Year: r.Year, Month: RamadanMonth, Day: d.Day}.Format(), d.Date)
+		if d.Date == today {
+			title = fmt.Sprintf("%s | %s", title, common.T("Today"))
+		}
+		var times []string
+		for _, pt := range []PrayTime{d.Imsak, d.Subuh, d.Maghrib} {
+			times = append(times, fmt.Sprintf("%s %s%s", pt.Name(), pt.DisplayValue, pt.AdjustmentNote()))
+		}
+		subtitle := fmt.Sprintf("%s  (%s)", strings.Join(times, "  "), common.Timespan(d.Length()).Format())
+		match := fmt.Sprintf("%d %s", d.Day, d.Date)
+		res.AddItem(common.AlfredResponseItem{
+			Title:    title,
+			Subtitle: &subtitle,
+			Match:    &match,
+			Arg:      d.Date,
+			Valid:    true,
+		})
+	}
+	return res
+}
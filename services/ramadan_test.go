@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestGetRamadan(t *testing.T) {
+	tests := []struct {
+		name        string
+		zoneId      string
+		year        int
+		unreachable bool
+		source      string
+		first, last string
+		err         bool
+	}{
+		{name: "published", zoneId: "WLY01", year: 1445, source: HijriSourceJakim, first: "11/03/2024", last: "09/04/2024"},
+		{name: "calculated", zoneId: "WLY01", year: 1446, unreachable: true, source: HijriSourceTabular, first: "01/03/2025", last: "30/03/2025"},
+		{name: "unknown zone offline", zoneId: "XXX99", year: 1446, unreachable: true, err: true},
+	}
+	for _, tt := range tests {
+		ctx, provider := newTestCtx(t)
+		if tt.unreachable {
+			provider.err = &url.Error{Op: "Post", URL: "https://www.e-solat.gov.my", Err: errors.New("no such host")}
+		}
+		r, err := GetRamadan(ctx, tt.zoneId, tt.year)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: got %d days, want an error", tt.name, len(r.Days))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", tt.name, err)
+			continue
+		}
+		if r.Source != tt.source || len(r.Days) != 30 || r.Days[0].Date != tt.first || r.Days[29].Date != tt.last {
+			t.Errorf("%s: got %s, %d days from %s, want %s, 30 days from %s to %s",
+				tt.name, r.Source, len(r.Days), r.Days[0].Date, tt.source, tt.first, tt.last)
+			continue
+		}
+		for i, d := range r.Days {
+			if d.Day != i+1 || d.Imsak.Key != "Imsak" || d.Subuh.Key != "Subuh" || d.Maghrib.Key != "Maghrib" || d.Length() <= 0 {
+				t.Errorf("%s: unexpected day %d %+v", tt.name, i+1, d)
+				break
+			}
+		}
+	}
+}
+
+func TestRamadanCountdown(t *testing.T) {
+	today := Today()
+	day := func(date time.Time) FastingDay {
+		return FastingDay{
+			PrayerDate: PrayerDate{Date: date.Format(PrimaryDateLayout)},
+			Subuh:      PrayTime{Key: "Subuh", Time: date.Add(6 * time.Hour)},
+			Maghrib:    PrayTime{Key: "Maghrib", Time: date.Add(19*time.Hour + 20*time.Minute)},
+		}
+	}
+	during := &Ramadan{Days: []FastingDay{day(today.AddDate(0, 0, -1)), day(today), day(today.AddDate(0, 0, 1))}}
+	lastDay := &Ramadan{Days: []FastingDay{day(today.AddDate(0, 0, -1)), day(today)}}
+	before := &Ramadan{Days: []FastingDay{day(today.AddDate(0, 0, 1))}}
+	tests := []struct {
+		name      string
+		ramadan   *Ramadan
+		now       time.Time
+		countdown string
+		remaining time.Duration
+		ok        bool
+	}{
+		{"before subuh", during, today.Add(5 * time.Hour), CountdownSahur, time.Hour, true},
+		{"during the fast", during, today.Add(12 * time.Hour), CountdownIftar, 7*time.Hour + 20*time.Minute, true},
+		{"after iftar", during, today.Add(21 * time.Hour), CountdownSahur, 9 * time.Hour, true},
+		{"after the last iftar", lastDay, today.Add(21 * time.Hour), "", 0, false},
+		{"before ramadan", before, today.Add(12 * time.Hour), "", 0, false},
+	}
+	for _, tt := range tests {
+		c, ok := tt.ramadan.Countdown(tt.now)
+		if ok != tt.ok || c.Type != tt.countdown || c.Remaining != tt.remaining {
+			t.Errorf("%s: got %v %s in %s, want %v %s in %s", tt.name, ok, c.Type, c.Remaining, tt.ok, tt.countdown, tt.remaining)
+		}
+	}
+}
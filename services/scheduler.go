@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	EventPrayer   = "prayer"
+	EventReminder = "reminder"
+)
+
+// Event is emitted by the Scheduler when a prayer time, or a reminder before it, is reached
+type Event struct {
+	Type   string
+	Prayer PrayTime
+	ZoneID string
+	Zone   *Zone
+	// Offset is how long before the prayer the event fires, negative after it and zero for EventPrayer
+	Offset time.Duration
+	// At is when the event is due
+	At time.Time
+}
+
+func (e Event) id() string {
+	return fmt.Sprintf("%s-%s-%s-%d", e.ZoneID, e.Prayer.Time.Format(idDateLayout), e.Prayer.Key, e.Offset)
+}
+
+// Notifier receives the events fired by the Scheduler
+type Notifier interface {
+	Notify(ev Event) error
+}
+
+// Subscriber is a Notifier that chooses its own reminder offsets instead of the scheduler's Offsets
+type Subscriber interface {
+	Notifier
+	Offsets() []time.Duration
+}
+
+// Scheduler waits for the next prayer time of a zone and notifies at the prayer time
+// and at each offset before it
+type Scheduler struct {
+	Ctx *common.Ctx
+	// ZoneID is the zone to follow, the default zone is re-read on every check when empty
+	ZoneID string
+	// Offsets before each prayer at which a reminder is fired
+	Offsets []time.Duration
+	// Prayers limits the events to these keys, every prayer is included when empty
+	Prayers   []string
+	Notifiers []Notifier
+	// CheckInterval bounds how long the scheduler sleeps before re-reading the cache and config
+	CheckInterval time.Duration
+	// LateTolerance is how late an event may still be fired, e.g. after resuming from sleep
+	LateTolerance time.Duration
+}
+
+// Run blocks until runCtx is cancelled
+func (s *Scheduler) Run(runCtx context.Context) error {
+	if s.CheckInterval <= 0 {
+		s.CheckInterval = time.Minute
+	}
+	if s.LateTolerance <= 0 {
+		s.LateTolerance = 5 * time.Minute
+	}
+	fired := map[string]time.Time{}
+	last := time.Now()
+	for {
+		now := time.Now()
+		subscriptions := s.subscriptions()
+		events := s.upcomingEvents(last, now.Add(2*s.CheckInterval), subscriptions)
+		for _, ev := range events {
+			if ev.At.After(now) {
+				break
+			}
+			if _, ok := fired[ev.id()]; ok {
+				continue
+			}
+			fired[ev.id()] = ev.At
+			if now.Sub(ev.At) > s.LateTolerance {
+				log.Printf("Skipping %s %s, it was due at %s", ev.Type, ev.Prayer.Key, ev.At.Format(time.RFC3339))
+				continue
+			}
+			s.notify(ev, subscriptions)
+		}
+		for id, at := range fired {
+			if now.Sub(at) > 24*time.Hour {
+				delete(fired, id)
+			}
+		}
+		last = now
+
+		wait := s.CheckInterval
+		for _, ev := range events {
+			if d := ev.At.Sub(now); d > 0 {
+				if d < wait {
+					wait = d
+				}
+				break
+			}
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-runCtx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+func (s *Scheduler) notify(ev Event, subscriptions map[Notifier][]time.Duration) {
+	for _, n := range s.Notifiers {
+		if ev.Type == EventReminder && !containsDuration(subscriptions[n], ev.Offset) {
+			continue
+		}
+		if err := n.Notify(ev); err != nil {
+			log.Printf("Unable to notify %s %s: %s", ev.Type, ev.Prayer.Key, err)
+		}
+	}
+}
+
+// subscriptions maps each notifier to the reminder offsets it receives
+func (s *Scheduler) subscriptions() map[Notifier][]time.Duration {
+	res := make(map[Notifier][]time.Duration, len(s.Notifiers))
+	for _, n := range s.Notifiers {
+		if sub, ok := n.(Subscriber); ok {
+			res[n] = sub.Offsets()
+		} else {
+			res[n] = s.Offsets
+		}
+	}
+	return res
+}
+
+func containsDuration(ds []time.Duration, d time.Duration) bool {
+	for _, v := range ds {
+		if v == d {
+			return true
+		}
+	}
+	return false
+}
+
+// upcomingEvents lists the events due between since and until, sorted by time
+func (s *Scheduler) upcomingEvents(since time.Time, until time.Time, subscriptions map[Notifier][]time.Duration) []Event {
+	var offsets []time.Duration
+	for _, ds := range subscriptions {
+		for _, d := range ds {
+			if d != 0 && !containsDuration(offsets, d) {
+				offsets = append(offsets, d)
+			}
+		}
+	}
+	zoneId := s.ZoneID
+	if len(zoneId) == 0 {
+		zoneId = GetConfig(s.Ctx, ConfigZone)
+	}
+	local := since.In(JakimLocation)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, JakimLocation)
+	var events []Event
+	// Yesterday is included for offsets after a late prayer that fall past midnight
+	for _, p := range GetPrayerTimes(s.Ctx, zoneId, day.AddDate(0, 0, -1), day.AddDate(0, 0, 1)) {
+		for _, pt := range p.Times {
+			if !s.includes(pt.Key) {
+				continue
+			}
+			ev := Event{Type: EventPrayer, Prayer: pt, ZoneID: p.ZoneID, Zone: p.Zone, At: pt.Time}
+			for _, offset := range offsets {
+				reminder := ev
+				reminder.Type = EventReminder
+				reminder.Offset = offset
+				reminder.At = pt.Time.Add(-offset)
+				events = append(events, reminder)
+			}
+			events = append(events, ev)
+		}
+	}
+	var res []Event
+	for _, ev := range events {
+		if ev.At.After(since) && !ev.At.After(until) {
+			res = append(res, ev)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].At.Before(res[j].At)
+	})
+	return res
+}
+
+func (s *Scheduler) includes(key string) bool {
+	if len(s.Prayers) == 0 {
+		return true
+	}
+	for _, p := range s.Prayers {
+		if strings.EqualFold(p, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// UpcomingPrayers returns the prayer times of a zone after since, up to the end of the following day
+func UpcomingPrayers(ctx *common.Ctx, zoneId string, since time.Time) []PrayTime {
+	local := since.In(JakimLocation)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, JakimLocation)
+	var res []PrayTime
+	for _, p := range GetPrayerTimes(ctx, zoneId, day, day.AddDate(0, 0, 1)) {
+		for _, pt := range p.Times {
+			if pt.Time.After(since) {
+				res = append(res, pt)
+			}
+		}
+	}
+	return res
+}
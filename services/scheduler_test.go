@@ -0,0 +1,73 @@
+package services
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// openFiles counts the file descriptors of the test process, it skips where they can't be listed
+func openFiles(t *testing.T) int {
+	t.Helper()
+	if runtime.GOOS != "linux" {
+		t.Skip("open files are only counted on linux")
+	}
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		t.Skip(err)
+	}
+	return len(entries)
+}
+
+func TestUpcomingEvents(t *testing.T) {
+	ctx, _ := newTestCtx(t)
+	s := &Scheduler{Ctx: ctx, ZoneID: "WLY01", Prayers: []string{"Subuh", "Maghrib"}}
+	subscriptions := map[Notifier][]time.Duration{nil: {10 * time.Minute}}
+	since := date(t, "2024-03-12").Add(6 * time.Hour)
+	events := s.upcomingEvents(since, since.Add(24*time.Hour), subscriptions)
+	want := []struct {
+		Type   string
+		Prayer string
+		At     string
+	}{
+		{EventReminder, "Maghrib", "2024-03-12 19:10"},
+		{EventPrayer, "Maghrib", "2024-03-12 19:20"},
+		{EventReminder, "Subuh", "2024-03-13 05:50"},
+		{EventPrayer, "Subuh", "2024-03-13 06:00"},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		ev := events[i]
+		if at := ev.At.In(JakimLocation).Format("2006-01-02 15:04"); ev.Type != w.Type || ev.Prayer.Key != w.Prayer || at != w.At {
+			t.Errorf("event %d: got %s %s at %s, want %s %s at %s", i, ev.Type, ev.Prayer.Key, at, w.Type, w.Prayer, w.At)
+		}
+	}
+}
+
+func TestUpcomingEventsReusesDb(t *testing.T) {
+	ctx, _ := newTestCtx(t)
+	GetZoneStates(ctx)
+	if _, err := SetConfig(ctx, "", ConfigZone, "WLY01"); err != nil {
+		t.Fatal(err)
+	}
+	s := &Scheduler{Ctx: ctx}
+	hooks := &HookNotifier{Ctx: ctx}
+	since := date(t, "2024-03-12")
+	check := func() {
+		subscriptions := map[Notifier][]time.Duration{hooks: hooks.Offsets()}
+		if events := s.upcomingEvents(since, since.Add(24*time.Hour), subscriptions); len(events) == 0 {
+			t.Fatal("no events")
+		}
+	}
+	check()
+	before := openFiles(t)
+	for i := 0; i < 100; i++ {
+		check()
+	}
+	if after := openFiles(t); after > before+2 {
+		t.Errorf("%d files open after 100 checks, %d before", after, before)
+	}
+}
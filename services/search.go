@@ -0,0 +1,128 @@
+package services
+
+import (
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type ZoneMatches []ZoneMatch
+
+// ZoneMatch is a zone found by SearchZones
+type ZoneMatch struct {
+	Zone  Zone
+	State string
+	Score int
+}
+
+// SearchZones ranks the zones matching query against their id, locations and state name.
+// Every word of the query has to match, either as a substring or as a subsequence of letters.
+func SearchZones(states []State, query string) ZoneMatches {
+	words := strings.Fields(normalizeSearch(query))
+	var res ZoneMatches
+	for _, s := range states {
+		for _, z := range s.Zones {
+			fields := []string{normalizeSearch(z.ID), normalizeSearch(z.Locations), normalizeSearch(s.Name)}
+			total := 0
+			for _, w := range words {
+				best := 0
+				for i, f := range fields {
+					score := matchScore(f, w)
+					if i == 0 && score > 0 {
+						// Zone ids are the most specific field
+						score *= 2
+					}
+					if score > best {
+						best = score
+					}
+				}
+				if best == 0 {
+					total = 0
+					break
+				}
+				total += best
+			}
+			if total > 0 {
+				res = append(res, ZoneMatch{Zone: z, State: s.Name, Score: total})
+			}
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Score > res[j].Score
+	})
+	return res
+}
+
+// matchScore scores how well word matches text, zero means no match
+func matchScore(text string, word string) int {
+	if len(word) == 0 {
+		return 0
+	}
+	if text == word {
+		return 100
+	}
+	if idx := strings.Index(text, word); idx >= 0 {
+		score := 60
+		if prev, _ := utf8.DecodeLastRuneInString(text[:idx]); idx == 0 || !isAlnum(prev) {
+			// Start of a word
+			score += 20
+		}
+		return score - minInt(idx, 20)/4
+	}
+	// Subsequence match, rewarding consecutive letters
+	chars := []rune(text)
+	n := len([]rune(word))
+	score, first, last, ti := 0, -1, -2, 0
+	for _, r := range word {
+		found := false
+		for ; ti < len(chars); ti++ {
+			if chars[ti] == r {
+				if ti == last+1 {
+					score += 3
+				} else {
+					score++
+				}
+				if first < 0 {
+					first = ti
+				}
+				last = ti
+				ti++
+				found = true
+				break
+			}
+		}
+		if !found {
+			return 0
+		}
+	}
+	// Scattered letters are mostly noise, only keep compact matches
+	if score < n*2 || last-first >= n*2 {
+		return 0
+	}
+	return minInt(score, 40)
+}
+
+// normalizeSearch lower cases s and removes accents
+func normalizeSearch(s string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	res, _, err := transform.String(t, s)
+	if err != nil {
+		res = s
+	}
+	return strings.ToLower(res)
+}
+
+func isAlnum(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
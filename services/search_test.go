@@ -0,0 +1,80 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSearchZones(t *testing.T) {
+	states := []State{
+		{Name: "Selangor", Zones: []Zone{
+			{ID: "SGR01", Locations: "Gombak, Petaling, Sepang"},
+			{ID: "SGR03", Locations: "Klang, Kuala Langat"},
+		}},
+		{Name: "Wilayah Persekutuan", Zones: []Zone{
+			{ID: "WLY01", Locations: "Kuala Lumpur, Putrajaya"},
+			{ID: "WLY02", Locations: "Labuan"},
+		}},
+	}
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"wly01", []string{"WLY01"}},
+		{"WLY", []string{"WLY01", "WLY02"}},
+		// Earlier matches rank first
+		{"kuala", []string{"WLY01", "SGR03"}},
+		{"Kuála", []string{"WLY01", "SGR03"}},
+		{"selangor", []string{"SGR01", "SGR03"}},
+		{"sgr 03", []string{"SGR03"}},
+		{"kuala putrajaya", []string{"WLY01"}},
+		{"lbuan", []string{"WLY02"}},
+		{"kuala xyz", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, m := range SearchZones(states, tt.query) {
+			got = append(got, m.Zone.ID)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%q: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	bundled, err := (&CalculationProvider{}).Zones()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for query, want := range map[string]string{"kota bharu": "KTN01", "johor bahru": "JHR02", "kuala lumpur": "WLY01"} {
+		if res := SearchZones(bundled, query); len(res) == 0 || res[0].Zone.ID != want {
+			t.Errorf("%q: got %v, want %s first", query, res, want)
+		}
+	}
+}
+
+func TestMatchScore(t *testing.T) {
+	tests := []struct {
+		text string
+		word string
+		want int
+	}{
+		{"klang", "klang", 100},
+		{"klang", "kla", 80},
+		{"kuala langat", "langat", 79},
+		{"sungai petani", "tani", 58},
+		// Multi-byte letters before a match aren't word boundaries
+		{"aßbahn", "bahn", 60},
+		{"kota—bharu", "bharu", 79},
+		{"labuan", "lbuan", 11},
+		// Letters too far apart
+		{"kuala lumpur", "lumpr", 0},
+		{"kuala lumpur", "kr", 0},
+		{"klang", "", 0},
+	}
+	for _, tt := range tests {
+		if got := matchScore(tt.text, tt.word); got != tt.want {
+			t.Errorf("%q in %q: got %d, want %d", tt.word, tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"encoding/json"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server exposes zones and prayer times over HTTP as JSON
+type Server struct {
+	Ctx *common.Ctx
+	// MetricsZones are the zones exported by /metrics, the default zone when empty
+	MetricsZones []string
+}
+
+func NewServer(ctx *common.Ctx) *Server {
+	return &Server{Ctx: ctx}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeJSON(w, http.StatusMethodNotAllowed, ErrorResponse{Error: common.T("method not allowed")})
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 1 && parts[0] == "zones":
+		s.handleZones(w)
+	case len(parts) == 2 && parts[0] == "zones":
+		s.handleZone(w, parts[1])
+	case len(parts) == 2 && parts[0] == "times":
+		s.handleTimes(w, r, parts[1])
+	case len(parts) == 3 && parts[0] == "times" && parts[2] == "next":
+		s.handleNext(w, parts[1])
+	case len(parts) == 1 && parts[0] == "metrics":
+		s.handleMetrics(w)
+	default:
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: common.T("not found")})
+	}
+	log.Printf("%s %s %s", r.Method, r.URL.RequestURI(), time.Since(start).Round(time.Millisecond))
+}
+
+func (s *Server) handleZones(w http.ResponseWriter) {
+	zs := ZoneStates(GetZoneStates(s.Ctx))
+	if len(zs) == 0 {
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: common.T("zone list is not available")})
+		return
+	}
+	writeJSON(w, http.StatusOK, zs.ToJSONResponse())
+}
+
+func (s *Server) handleZone(w http.ResponseWriter, zoneId string) {
+	zone := s.findZone(zoneId)
+	if zone == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: common.T("zone with id [%s] not found", zoneId)})
+		return
+	}
+	writeJSON(w, http.StatusOK, zone)
+}
+
+func (s *Server) handleTimes(w http.ResponseWriter, r *http.Request, zoneId string) {
+	zone := s.findZone(zoneId)
+	if zone == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: common.T("zone with id [%s] not found", zoneId)})
+		return
+	}
+	query := r.URL.Query()
+	from, to, err := DateRange(query.Get("date"), query.Get("mode"), query.Get("from"), query.Get("to"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+	pts := PrayerDates(GetPrayerTimes(s.Ctx, zone.ID, from, to))
+	if len(pts) == 0 {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: common.T("no prayer times found for zone [%s]", zone.ID)})
+		return
+	}
+	writeJSON(w, http.StatusOK, pts.ToJSONResponse())
+}
+
+func (s *Server) handleNext(w http.ResponseWriter, zoneId string) {
+	zone := s.findZone(zoneId)
+	if zone == nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: common.T("zone with id [%s] not found", zoneId)})
+		return
+	}
+	next, err := GetNextPrayer(s.Ctx, zone.ID, time.Now())
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+		return
+	}
+	res := next.ToJSONResponse()
+	res.Zone = *zone
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) findZone(zoneId string) *ZoneResponse {
+	zoneId = strings.ToUpper(zoneId)
+	zs := ZoneStates(GetZoneStates(s.Ctx))
+	for _, state := range zs.ToJSONResponse() {
+		for _, z := range state.Zones {
+			if z.ID == zoneId {
+				return &z
+			}
+		}
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer(t *testing.T) {
+	ctx, _ := newTestCtx(t)
+	server := NewServer(ctx)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{"zones", http.MethodGet, "/zones", http.StatusOK},
+		{"zone", http.MethodGet, "/zones/wly01", http.StatusOK},
+		{"unknown zone", http.MethodGet, "/zones/xxx99", http.StatusNotFound},
+		{"times", http.MethodGet, "/times/WLY01?from=2024-03-11&to=2024-03-13", http.StatusOK},
+		{"times of an unknown zone", http.MethodGet, "/times/xxx99", http.StatusNotFound},
+		{"invalid mode", http.MethodGet, "/times/wly01?mode=hourly", http.StatusBadRequest},
+		{"invalid date", http.MethodGet, "/times/wly01?date=yesterdayish", http.StatusBadRequest},
+		{"to before from", http.MethodGet, "/times/wly01?from=2024-03-13&to=2024-03-11", http.StatusBadRequest},
+		{"wrong method", http.MethodPost, "/times/wly01", http.StatusMethodNotAllowed},
+		{"unknown path", http.MethodGet, "/prayers", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+			if rec.Code != tt.status {
+				t.Errorf("got %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				var res ErrorResponse
+				if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil || len(res.Error) == 0 {
+					t.Errorf("got %q, want an error body", rec.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestServerTimes(t *testing.T) {
+	ctx, _ := newTestCtx(t)
+	rec := httptest.NewRecorder()
+	NewServer(ctx).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/times/wly01?from=2024-03-11&to=2024-03-13", nil))
+	var res []PrayerDateResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 || res[0].Date != "2024-03-11" || res[2].Date != "2024-03-13" {
+		t.Errorf("got %+v, want 11 to 13 March 2024", res)
+	}
+}
+
+func TestOpenDbIsShared(t *testing.T) {
+	ctx, _ := newTestCtx(t)
+	first, err := OpenDb(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := OpenDb(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Error("OpenDb opened the database again")
+	}
+}
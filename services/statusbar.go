@@ -0,0 +1,117 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	ModeWaybar   = "waybar"
+	ModePolybar  = "polybar"
+	ModeI3blocks = "i3blocks"
+	ModeTmux     = "tmux"
+)
+
+// Status bar states, also used as the waybar class
+const (
+	StateNormal = "normal"
+	// StateSoon is when the next prayer is less than SoonWindow away
+	StateSoon = "soon"
+	// StateActive is when the current prayer started less than ActiveWindow ago
+	StateActive = "active"
+
+	SoonWindow   = 15 * time.Minute
+	ActiveWindow = 15 * time.Minute
+)
+
+// statusColors are the polybar and i3blocks colours of each state, normal uses the bar's own colour
+var statusColors = map[string]string{
+	StateSoon:   "#e5c07b",
+	StateActive: "#98c379",
+}
+
+var tmuxColors = map[string]string{
+	StateSoon:   "yellow",
+	StateActive: "green",
+}
+
+type WaybarResponse struct {
+	Text    string `json:"text"`
+	Tooltip string `json:"tooltip"`
+	Class   string `json:"class"`
+	Alt     string `json:"alt"`
+}
+
+// State tells whether the next prayer is approaching or the current one has just started
+func (n *NextPrayer) State() string {
+	if n.Current != nil && n.Current.IsCurrent && -n.Current.Duration < ActiveWindow {
+		return StateActive
+	}
+	if n.Remaining <= SoonWindow {
+		return StateSoon
+	}
+	return StateNormal
+}
+
+// statusPrayer is the prayer shown, the current one while it has just started and the next one otherwise
+func (n *NextPrayer) statusPrayer() *PrayTime {
+	if n.State() == StateActive {
+		return n.Current
+	}
+	return &n.Next
+}
+
+// StatusText is the text shown in a status bar, the current prayer while it has just started
+// and the next one otherwise
+func (n *NextPrayer) StatusText() string {
+	if n.State() == StateActive {
+		return n.Current.String()
+	}
+	return n.Short()
+}
+
+// Tooltip lists the times of the next prayer's day, marking the next one
+func (n *NextPrayer) Tooltip() string {
+	if n.PrayerDate == nil {
+		return n.Summary()
+	}
+	lines := []string{n.PrayerDate.Date}
+	for _, pt := range n.PrayerDate.Times {
+		line := fmt.Sprintf("%s\t%s%s", pt.Name(), pt.DisplayValue, pt.AdjustmentNote())
+		if pt.Key == n.Next.Key {
+			line += " *"
+		}
+		lines = append(lines, line)
+	}
+	if note := n.PrayerDate.CalculatedNote(); len(note) != 0 {
+		lines = append(lines, note)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// StatusLine renders the next prayer for one of the status bar output modes
+func (n *NextPrayer) StatusLine(mode string) string {
+	state := n.State()
+	text := n.StatusText()
+	switch mode {
+	case ModeWaybar:
+		bytes, _ := json.Marshal(WaybarResponse{Text: text, Tooltip: n.Tooltip(), Class: state, Alt: state})
+		return string(bytes)
+	case ModePolybar:
+		if color, ok := statusColors[state]; ok {
+			return fmt.Sprintf("%%{F%s}%s%%{F-}", color, text)
+		}
+		return text
+	case ModeI3blocks:
+		// full_text, short_text and color lines
+		return strings.TrimRight(fmt.Sprintf("%s\n%s\n%s", text, n.statusPrayer().Name(), statusColors[state]), "\n")
+	case ModeTmux:
+		if color, ok := tmuxColors[state]; ok {
+			return fmt.Sprintf("#[fg=%s]%s#[default]", color, text)
+		}
+		return text
+	}
+	return text
+}
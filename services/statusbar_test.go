@@ -0,0 +1,77 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNextPrayerState(t *testing.T) {
+	asar := &PrayTime{Key: "Asar", DisplayValue: "16:35", IsCurrent: true}
+	tests := []struct {
+		name      string
+		started   time.Duration
+		current   bool
+		remaining time.Duration
+		want      string
+	}{
+		{"far from both", time.Hour, true, 2 * time.Hour, StateNormal},
+		{"current just started", 5 * time.Minute, true, 2 * time.Hour, StateActive},
+		{"active window ended", ActiveWindow, true, 2 * time.Hour, StateNormal},
+		{"next approaching", time.Hour, true, 10 * time.Minute, StateSoon},
+		{"soon window starts", time.Hour, true, SoonWindow, StateSoon},
+		{"just started wins over soon", 5 * time.Minute, true, 10 * time.Minute, StateActive},
+		// Yesterday's isyak is never active
+		{"current of another day", 5 * time.Minute, false, 2 * time.Hour, StateNormal},
+	}
+	for _, tt := range tests {
+		current := *asar
+		current.Duration = -tt.started
+		current.IsCurrent = tt.current
+		n := &NextPrayer{Current: &current, Next: PrayTime{Key: "Maghrib", DisplayValue: "19:20"}, Remaining: tt.remaining}
+		if got := n.State(); got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+	}
+	if got := (&NextPrayer{Remaining: time.Hour}).State(); got != StateNormal {
+		t.Errorf("without a current prayer: got %s, want %s", got, StateNormal)
+	}
+}
+
+func TestNextPrayerStatusLine(t *testing.T) {
+	next := PrayTime{Key: "Maghrib", DisplayValue: "19:20"}
+	normal := &NextPrayer{Next: next, Remaining: 2*time.Hour + 5*time.Minute}
+	soon := &NextPrayer{Next: next, Remaining: 10 * time.Minute}
+	active := &NextPrayer{
+		Current:   &PrayTime{Key: "Asar", DisplayValue: "16:35", IsCurrent: true, Duration: -5 * time.Minute},
+		Next:      next,
+		Remaining: 2*time.Hour + 40*time.Minute,
+	}
+	tests := []struct {
+		mode string
+		n    *NextPrayer
+		want string
+	}{
+		{"cli", normal, "Maghrib 19:20 -2:05"},
+		{ModePolybar, normal, "Maghrib 19:20 -2:05"},
+		{ModePolybar, soon, "%{F#e5c07b}Maghrib 19:20 -0:10%{F-}"},
+		{ModePolybar, active, "%{F#98c379}Asar 16:35%{F-}"},
+		{ModeI3blocks, normal, "Maghrib 19:20 -2:05\nMaghrib"},
+		{ModeI3blocks, active, "Asar 16:35\nAsar\n#98c379"},
+		{ModeTmux, normal, "Maghrib 19:20 -2:05"},
+		{ModeTmux, soon, "#[fg=yellow]Maghrib 19:20 -0:10#[default]"},
+	}
+	for _, tt := range tests {
+		if got := tt.n.StatusLine(tt.mode); got != tt.want {
+			t.Errorf("%s %s: got %q, want %q", tt.mode, tt.n.State(), got, tt.want)
+		}
+	}
+
+	var waybar WaybarResponse
+	if err := json.Unmarshal([]byte(soon.StatusLine(ModeWaybar)), &waybar); err != nil {
+		t.Fatal(err)
+	}
+	if waybar.Text != "Maghrib 19:20 -0:10" || waybar.Class != StateSoon || waybar.Alt != StateSoon || len(waybar.Tooltip) == 0 {
+		t.Errorf("waybar: got %+v", waybar)
+	}
+}
@@ -5,19 +5,61 @@ import (
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"strings"
+	"sync"
 )
 
+// databases are the opened databases by path, each is opened and migrated once per process and shared
+var databases = struct {
+	sync.Mutex
+	dbs map[string]*gorm.DB
+}{dbs: map[string]*gorm.DB{}}
+
 func OpenDb(ctx *common.Ctx) (*gorm.DB, error) {
+	databases.Lock()
+	defer databases.Unlock()
+	if db, ok := databases.dbs[ctx.Config.DbPath]; ok {
+		return db, nil
+	}
 	loggerMode := logger.Silent
 	if ctx.Config.IsDebug && !ctx.Config.IsAlfred() {
 		loggerMode = logger.Warn
 	}
-	db, err := gorm.Open(sqlite.Open(ctx.Config.DbPath), &gorm.Config{
+	dsn := ctx.Config.DbPath
+	if !strings.Contains(dsn, "?") {
+		// Wait for the writes of concurrent requests or processes instead of failing
+		dsn += "?_busy_timeout=5000"
+	}
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(loggerMode),
 	})
 	if err != nil {
 		return nil, err
 	}
-	err = db.AutoMigrate(&PrayerDate{}, &UserConfig{})
-	return db, err
+	if err = db.AutoMigrate(&PrayerDate{}, &UserConfig{}, &FetchRecord{}, &Hook{}); err != nil {
+		closeDb(db)
+		return nil, err
+	}
+	if err = migrateStoredTimes(db); err != nil {
+		closeDb(db)
+		return nil, err
+	}
+	databases.dbs[ctx.Config.DbPath] = db
+	return db, nil
+}
+
+// CloseDb closes the database of ctx, the next OpenDb opens it again
+func CloseDb(ctx *common.Ctx) {
+	databases.Lock()
+	defer databases.Unlock()
+	if db, ok := databases.dbs[ctx.Config.DbPath]; ok {
+		closeDb(db)
+		delete(databases.dbs, ctx.Config.DbPath)
+	}
+}
+
+func closeDb(db *gorm.DB) {
+	if sqlDb, err := db.DB(); err == nil {
+		_ = sqlDb.Close()
+	}
 }
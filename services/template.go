@@ -0,0 +1,122 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateData is what --format templates are executed with
+type TemplateData struct {
+	// PrayerDate is the first day shown, PrayerDates every day of the range
+	PrayerDate  *PrayerDate
+	PrayerDates []PrayerDate
+	Zone        *Zone
+	// Times are the prayer times of PrayerDate
+	Times []PrayTime
+	// Current and Next come from the current time, Next may be on the following day
+	Current   *PrayTime
+	Next      *PrayTime
+	Remaining time.Duration
+	Now       time.Time
+}
+
+// Prayer returns the time of a prayer key of PrayerDate, e.g. {{ (.Prayer "Maghrib").DisplayValue }}
+func (d *TemplateData) Prayer(key string) *PrayTime {
+	for i := range d.Times {
+		if strings.EqualFold(d.Times[i].Key, key) {
+			return &d.Times[i]
+		}
+	}
+	return nil
+}
+
+// NewTemplateData combines prayer dates and the next prayer, either may be empty
+func NewTemplateData(prayerDates []PrayerDate, next *NextPrayer, now time.Time) TemplateData {
+	data := TemplateData{PrayerDates: prayerDates, Now: now}
+	if len(prayerDates) != 0 {
+		data.PrayerDate = &prayerDates[0]
+		data.Zone = prayerDates[0].Zone
+		data.Times = prayerDates[0].Times
+	}
+	if next != nil {
+		data.Current = next.Current
+		data.Next = &next.Next
+		data.Remaining = next.Remaining
+		if data.Zone == nil {
+			data.Zone = next.Zone
+		}
+		if data.PrayerDate == nil && next.PrayerDate != nil {
+			data.PrayerDate = next.PrayerDate
+			data.Times = next.PrayerDate.Times
+		}
+	}
+	return data
+}
+
+var templateFuncs = template.FuncMap{
+	// duration describes a duration in the current language, e.g. 2hours 5min
+	"duration": func(d time.Duration) string {
+		return common.Timespan(d.Round(time.Second)).Format()
+	},
+	// clock formats a duration as H:MM
+	"clock": func(d time.Duration) string {
+		d = d.Round(time.Minute)
+		return fmt.Sprintf("%d:%02d", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	},
+	// format formats a time with a Go layout, e.g. {{ format "15:04" .Next.Time }}
+	"format": func(layout string, t time.Time) string {
+		return t.Format(layout)
+	},
+	"until": func(t time.Time) time.Duration {
+		return time.Until(t).Round(time.Second)
+	},
+	"since": func(t time.Time) time.Duration {
+		return time.Since(t).Round(time.Second)
+	},
+	"prayerName": PrayerName,
+	"t":          common.T,
+	"upper":      strings.ToUpper,
+	"lower":      strings.ToLower,
+}
+
+// ParseTemplate parses a --format template
+func ParseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("format").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid format template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// ExecuteTemplate renders a --format template, a trailing newline is added when missing
+func ExecuteTemplate(text string, data TemplateData) (string, error) {
+	tmpl, err := ParseTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err = tmpl.Execute(&buf, &data); err != nil {
+		return "", err
+	}
+	res := buf.String()
+	if !strings.HasSuffix(res, "\n") {
+		res += "\n"
+	}
+	return res, nil
+}
+
+// OutputTemplate returns the template to print with, --format first and then the format setting,
+// which only applies to the cli output mode
+func OutputTemplate(ctx *common.Ctx) string {
+	if len(ctx.Config.Format) != 0 {
+		return ctx.Config.Format
+	}
+	if ctx.Config.IsAlfred() || ctx.Config.IsJSON() || ctx.Config.IsStatusBar() {
+		return ""
+	}
+	return GetConfig(ctx, ConfigFormat)
+}
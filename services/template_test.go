@@ -0,0 +1,59 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExecuteTemplate(t *testing.T) {
+	day := date(t, "2024-03-12")
+	zone := &Zone{ID: "WLY01", Locations: "Kuala Lumpur, Putrajaya"}
+	times := []PrayTime{
+		{Key: "Subuh", Time: day.Add(6 * time.Hour), DisplayValue: "06:00"},
+		{Key: "Asar", Time: day.Add(16*time.Hour + 35*time.Minute), DisplayValue: "16:35", IsCurrent: true},
+		{Key: "Maghrib", Time: day.Add(19*time.Hour + 20*time.Minute), DisplayValue: "19:20", Offset: 2 * time.Minute},
+	}
+	prayerDates := []PrayerDate{{Date: "12/03/2024", Zone: zone, Times: times}, {Date: "13/03/2024", Zone: zone}}
+	next := &NextPrayer{ZoneID: "WLY01", Zone: zone, Current: &times[1], Next: times[2], Remaining: 2*time.Hour + 5*time.Minute}
+	now := day.Add(17*time.Hour + 15*time.Minute)
+	full := NewTemplateData(prayerDates, next, now)
+	nextOnly := NewTemplateData(nil, &NextPrayer{Zone: zone, Next: times[2], PrayerDate: &prayerDates[0]}, now)
+	empty := NewTemplateData(nil, nil, now)
+
+	tests := []struct {
+		text string
+		data TemplateData
+		want string
+		err  bool
+	}{
+		{text: "{{ .Zone.ID }} {{ .PrayerDate.Date }}", data: full, want: "WLY01 12/03/2024\n"},
+		{text: `{{ (.Prayer "maghrib").DisplayValue }} {{ (.Prayer "Maghrib").IsAdjusted }}`, data: full, want: "19:20 true\n"},
+		{text: "{{ .Current.Key }} -> {{ .Next.Key }} {{ clock .Remaining }}", data: full, want: "Asar -> Maghrib 2:05\n"},
+		{text: `{{ format "2006-01-02 15:04" .Next.Time }} {{ upper .Next.Key }} {{ lower .Zone.ID }}`, data: full, want: "2024-03-12 19:20 MAGHRIB wly01\n"},
+		{text: "{{ range .Times }}{{ .Key }} {{ end }}", data: full, want: "Subuh Asar Maghrib \n"},
+		{text: "{{ len .PrayerDates }} {{ format \"15:04\" .Now }}", data: full, want: "2 17:15\n"},
+		{text: `{{ prayerName "Subuh" }}`, data: full, want: "Subuh\n"},
+		// Without prayer dates the day of the next prayer is used
+		{text: "{{ .Zone.ID }} {{ .PrayerDate.Date }} {{ len .Times }}", data: nextOnly, want: "WLY01 12/03/2024 3\n"},
+		{text: "{{ if .Next }}{{ .Next.Key }}{{ else }}none{{ end }}", data: empty, want: "none\n"},
+		{text: `{{ with .Prayer "Isyak" }}{{ .DisplayValue }}{{ else }}-{{ end }}`, data: full, want: "-\n"},
+		{text: "line\n", data: full, want: "line\n"},
+		{text: "{{ .Next.Key", data: full, err: true},
+		{text: "{{ .Missing }}", data: full, err: true},
+		{text: `{{ unknown "x" }}`, data: full, err: true},
+	}
+	for _, tt := range tests {
+		got, err := ExecuteTemplate(tt.text, tt.data)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: got %q, want an error", tt.text, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %s", tt.text, err)
+		} else if got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"fmt"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+const (
+	// StoredTimeLayout is the layout prayer times are cached in, it never depends on the display format
+	StoredTimeLayout = "15:04"
+	// legacyStoredTimeLayout is the 12 hour layout older versions cached prayer times in
+	legacyStoredTimeLayout = "03:04PM"
+	// storedTimeLayoutKey is the UserConfig key recording the layout of the cached times
+	storedTimeLayoutKey = "STORED_TIME_LAYOUT"
+
+	TimeFormat12h = "12h"
+	TimeFormat24h = "24h"
+)
+
+var timeFormats = map[string]string{
+	TimeFormat12h: "03:04PM",
+	TimeFormat24h: "15:04",
+}
+
+// migrateStoredTimes converts times cached in legacyStoredTimeLayout to StoredTimeLayout, once
+func migrateStoredTimes(db *gorm.DB) error {
+	marker := &UserConfig{}
+	if db.First(marker, "id=?", storedTimeLayoutKey).Error == nil && marker.Value == StoredTimeLayout {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, key := range PrayerKeys() {
+			column := strings.ToLower(key)
+			// 07:05PM -> 19:05, 12:10AM -> 00:10
+			expr := fmt.Sprintf("printf('%%02d:%%s', (CAST(substr(%[1]s, 1, 2) AS INTEGER) %% 12) + "+
+				"CASE WHEN substr(%[1]s, 6, 2) = 'PM' THEN 12 ELSE 0 END, substr(%[1]s, 4, 2))", column)
+			err := tx.Model(&PrayerDate{}).Unscoped().
+				Where(fmt.Sprintf("%s LIKE '%%M'", column)).
+				Update(column, gorm.Expr(expr)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Save(&UserConfig{ID: storedTimeLayoutKey, Value: StoredTimeLayout}).Error
+	})
+}
+
+// ParseTimeFormat resolves 12h, 24h or a custom Go time layout into a layout
+func ParseTimeFormat(format string) (string, error) {
+	if layout, ok := timeFormats[strings.ToLower(format)]; ok {
+		return layout, nil
+	}
+	// A layout without any time elements formats to itself
+	ref := time.Date(2001, 11, 12, 13, 45, 56, 0, time.UTC)
+	if len(strings.TrimSpace(format)) == 0 || ref.Format(format) == format {
+		return "", fmt.Errorf("invalid time format [%s], expected 12h, 24h or a Go time layout such as 3:04 pm", format)
+	}
+	return format, nil
+}
+
+// DisplayTimeLayout returns the layout prayer times are shown in,
+// from --time-format, then the time-format setting, then 12 hour time
+func DisplayTimeLayout(ctx *common.Ctx) string {
+	format := ctx.Config.TimeFormat
+	if len(format) == 0 {
+		format = GetConfig(ctx, ConfigTimeFormat)
+	}
+	if layout, err := ParseTimeFormat(format); err == nil {
+		return layout
+	}
+	return timeFormats[TimeFormat12h]
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"testing"
+)
+
+// TestMigrateStoredTimes opens a cache written by a version storing 12 hour times
+func TestMigrateStoredTimes(t *testing.T) {
+	ctx, _ := newTestCtx(t)
+	legacy, err := gorm.Open(sqlite.Open(ctx.Config.DbPath), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = legacy.AutoMigrate(&PrayerDate{}, &UserConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	seed := []PrayerDate{
+		{ID: "20240312-WLY01", Date: "12/03/2024", ZoneID: "WLY01", Imsak: "05:50AM", Subuh: "06:00AM", Syuruk: "07:10AM",
+			Zohor: "12:30PM", Asar: "04:35PM", Maghrib: "07:05PM", Isyak: "08:30PM"},
+		// Midnight and times already migrated by an interrupted run
+		{ID: "20240313-WLY01", Date: "13/03/2024", ZoneID: "WLY01", Imsak: "12:10AM", Subuh: "06:00", Syuruk: "07:10",
+			Zohor: "13:15", Asar: "16:35", Maghrib: "19:20", Isyak: "11:59PM"},
+	}
+	if err = legacy.Create(&seed).Error; err != nil {
+		t.Fatal(err)
+	}
+	closeDb(legacy)
+
+	db, err := OpenDb(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"20240312-WLY01": {"05:50", "06:00", "07:10", "12:30", "16:35", "19:05", "20:30"},
+		"20240313-WLY01": {"00:10", "06:00", "07:10", "13:15", "16:35", "19:20", "23:59"},
+	}
+	check := func() {
+		t.Helper()
+		for id, times := range want {
+			p := &PrayerDate{}
+			if err := db.First(p, "id = ?", id).Error; err != nil {
+				t.Fatal(err)
+			}
+			got := []string{p.Imsak, p.Subuh, p.Syuruk, p.Zohor, p.Asar, p.Maghrib, p.Isyak}
+			for i := range times {
+				if got[i] != times[i] {
+					t.Errorf("%s: got %v, want %v", id, got, times)
+					break
+				}
+			}
+		}
+	}
+	check()
+	if marker := GetUserConfig(ctx, storedTimeLayoutKey, ""); marker != StoredTimeLayout {
+		t.Errorf("got layout marker %q, want %q", marker, StoredTimeLayout)
+	}
+
+	// Running it again, even without the marker, leaves 24 hour times as they are
+	if err = db.Delete(&UserConfig{}, "id = ?", storedTimeLayoutKey).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err = migrateStoredTimes(db); err != nil {
+		t.Fatal(err)
+	}
+	check()
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"fmt"
+	"github.com/sayuthisobri/waktu-solat/common"
+	"strings"
+	"time"
+	// Embedded so the JAKIM time zone resolves on systems without a zoneinfo database
+	_ "time/tzdata"
+)
+
+const JakimTimezone = "Asia/Kuala_Lumpur"
+
+// JakimLocation is the time zone every JAKIM time is published in, whatever the system time zone is
+var JakimLocation = loadJakimLocation()
+
+func loadJakimLocation() *time.Location {
+	if loc, err := time.LoadLocation(JakimTimezone); err == nil {
+		return loc
+	}
+	return time.FixedZone("MYT", 8*60*60)
+}
+
+// Today returns midnight of the current date in Malaysia
+func Today() time.Time {
+	now := time.Now().In(JakimLocation)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, JakimLocation)
+}
+
+// ParseTimezone resolves an IANA time zone name, or local for the system time zone
+func ParseTimezone(name string) (*time.Location, error) {
+	if strings.EqualFold(name, "local") {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone [%s], expected an IANA name such as Europe/London, or local", name)
+	}
+	return loc, nil
+}
+
+// DisplayLocation returns the time zone times are shown in, from --tz, defaulting to Malaysia
+func DisplayLocation(ctx *common.Ctx) *time.Location {
+	if len(ctx.Config.Timezone) != 0 {
+		if loc, err := ParseTimezone(ctx.Config.Timezone); err == nil {
+			return loc
+		}
+	}
+	return JakimLocation
+}
@@ -0,0 +1,123 @@
+package services
+
+// Coordinate is a position in decimal degrees
+type Coordinate struct {
+	Latitude  float64
+	Longitude float64
+}
+
+type bundledZone struct {
+	ID        string
+	Locations string
+	// Reference point used for offline calculation, usually the zone's main town
+	Coordinate Coordinate
+}
+
+type bundledState struct {
+	ID    string
+	Name  string
+	Zones []bundledZone
+}
+
+// bundledStates is a copy of JAKIM's zone list, used when the zones can't be fetched
+var bundledStates = []bundledState{
+	{"JHR", "Johor", []bundledZone{
+		{"JHR01", "Pulau Aur, Pulau Pemanggil", Coordinate{2.4500, 104.5200}},
+		{"JHR02", "Johor Bahru, Kota Tinggi, Mersing, Kulai", Coordinate{1.4927, 103.7414}},
+		{"JHR03", "Kluang, Pontian", Coordinate{2.0301, 103.3185}},
+		{"JHR04", "Batu Pahat, Muar, Segamat, Gemas Johor, Tangkak", Coordinate{1.8548, 102.9325}},
+	}},
+	{"KDH", "Kedah", []bundledZone{
+		{"KDH01", "Kota Setar, Kubang Pasu, Pokok Sena (Daerah Kecil)", Coordinate{6.1248, 100.3678}},
+		{"KDH02", "Kuala Muda, Yan, Pendang", Coordinate{5.6470, 100.4877}},
+		{"KDH03", "Padang Terap, Sik", Coordinate{5.8167, 100.7333}},
+		{"KDH04", "Baling", Coordinate{5.6766, 100.9171}},
+		{"KDH05", "Bandar Baharu, Kulim", Coordinate{5.3650, 100.5617}},
+		{"KDH06", "Langkawi", Coordinate{6.3256, 99.8432}},
+		{"KDH07", "Puncak Gunung Jerai", Coordinate{5.7878, 100.4330}},
+	}},
+	{"KTN", "Kelantan", []bundledZone{
+		{"KTN01", "Bachok, Kota Bharu, Machang, Pasir Mas, Pasir Puteh, Tanah Merah, Tumpat, Kuala Krai, Mukim Chiku", Coordinate{6.1254, 102.2381}},
+		{"KTN02", "Gua Musang (Daerah Galas, Bertam), Jeli, Jajahan Kecil Lojing", Coordinate{4.8823, 101.9644}},
+	}},
+	{"MLK", "Melaka", []bundledZone{
+		{"MLK01", "Seluruh Negeri Melaka", Coordinate{2.1896, 102.2501}},
+	}},
+	{"NGS", "Negeri Sembilan", []bundledZone{
+		{"NGS01", "Tampin, Jempol", Coordinate{2.4701, 102.2302}},
+		{"NGS02", "Jelebu, Kuala Pilah, Rembau", Coordinate{2.7389, 102.2487}},
+		{"NGS03", "Port Dickson, Seremban", Coordinate{2.7259, 101.9424}},
+	}},
+	{"PHG", "Pahang", []bundledZone{
+		{"PHG01", "Pulau Tioman", Coordinate{2.7900, 104.1700}},
+		{"PHG02", "Kuantan, Pekan, Rompin, Muadzam Shah", Coordinate{3.8077, 103.3260}},
+		{"PHG03", "Jerantut, Temerloh, Maran, Bera, Chenor, Jengka", Coordinate{3.4500, 102.4176}},
+		{"PHG04", "Bentong, Lipis, Raub", Coordinate{3.5220, 101.9080}},
+		{"PHG05", "Genting Sempah, Janda Baik, Bukit Tinggi", Coordinate{3.3300, 101.8600}},
+		{"PHG06", "Cameron Highlands, Genting Higlands, Bukit Fraser", Coordinate{4.4721, 101.3801}},
+	}},
+	{"PLS", "Perlis", []bundledZone{
+		{"PLS01", "Kangar, Padang Besar, Arau", Coordinate{6.4414, 100.1986}},
+	}},
+	{"PNG", "Pulau Pinang", []bundledZone{
+		{"PNG01", "Seluruh Negeri Pulau Pinang", Coordinate{5.4141, 100.3288}},
+	}},
+	{"PRK", "Perak", []bundledZone{
+		{"PRK01", "Tapah, Slim River, Tanjung Malim", Coordinate{4.1986, 101.2614}},
+		{"PRK02", "Kuala Kangsar, Sg. Siput, Ipoh, Batu Gajah, Kampar", Coordinate{4.5975, 101.0901}},
+		{"PRK03", "Lenggong, Pengkalan Hulu, Grik", Coordinate{5.4296, 101.1262}},
+		{"PRK04", "Temengor, Belum", Coordinate{5.5000, 101.3300}},
+		{"PRK05", "Kg Gajah, Teluk Intan, Bagan Datuk, Seri Iskandar, Beruas, Parit, Lumut, Sitiawan, Pulau Pangkor", Coordinate{4.0259, 101.0213}},
+		{"PRK06", "Selama, Taiping, Bagan Serai, Parit Buntar", Coordinate{4.8500, 100.7333}},
+		{"PRK07", "Bukit Larut", Coordinate{4.8620, 100.7930}},
+	}},
+	{"SBH", "Sabah", []bundledZone{
+		{"SBH01", "Bahagian Sandakan (Timur), Bukit Garam, Semawang, Temanggong, Tambisan, Bandar Sandakan, Sukau", Coordinate{5.8402, 118.1179}},
+		{"SBH02", "Beluran, Telupid, Pinangah, Terusan, Kuamut, Bahagian Sandakan (Barat)", Coordinate{5.8944, 117.5550}},
+		{"SBH03", "Lahad Datu, Silabukan, Kunak, Sahabat, Semporna, Tungku, Bahagian Tawau (Timur)", Coordinate{5.0268, 118.3270}},
+		{"SBH04", "Bandar Tawau, Balong, Merotai, Kalabakan, Bahagian Tawau (Barat)", Coordinate{4.2448, 117.8912}},
+		{"SBH05", "Kudat, Kota Marudu, Pitas, Pulau Banggi, Bahagian Kudat", Coordinate{6.8837, 116.8477}},
+		{"SBH06", "Gunung Kinabalu", Coordinate{6.0750, 116.5580}},
+		{"SBH07", "Kota Kinabalu, Ranau, Kota Belud, Tuaran, Penampang, Papar, Putatan, Bahagian Pantai Barat", Coordinate{5.9804, 116.0735}},
+		{"SBH08", "Pensiangan, Keningau, Tambunan, Nabawan, Bahagian Pendalaman (Atas)", Coordinate{5.3378, 116.1602}},
+		{"SBH09", "Beaufort, Kuala Penyu, Sipitang, Tenom, Long Pasia, Membakut, Weston, Bahagian Pendalaman (Bawah)", Coordinate{5.3473, 115.7455}},
+	}},
+	{"SGR", "Selangor", []bundledZone{
+		{"SGR01", "Gombak, Petaling, Sepang, Hulu Langat, Hulu Selangor, S.Alam", Coordinate{3.0733, 101.5185}},
+		{"SGR02", "Kuala Selangor, Sabak Bernam", Coordinate{3.3400, 101.2500}},
+		{"SGR03", "Klang, Kuala Langat", Coordinate{3.0449, 101.4456}},
+	}},
+	{"SWK", "Sarawak", []bundledZone{
+		{"SWK01", "Limbang, Lawas, Sundar, Trusan", Coordinate{4.7548, 115.0089}},
+		{"SWK02", "Miri, Niah, Bekenu, Sibuti, Marudi", Coordinate{4.3995, 113.9914}},
+		{"SWK03", "Pandan, Belaga, Suai, Tatau, Sebauh, Bintulu", Coordinate{3.1713, 113.0419}},
+		{"SWK04", "Sibu, Mukah, Dalat, Song, Igan, Oya, Balingian, Kanowit, Kapit", Coordinate{2.2870, 111.8300}},
+		{"SWK05", "Sarikei, Matu, Julau, Rajang, Daro, Bintangor, Belawai", Coordinate{2.1271, 111.5182}},
+		{"SWK06", "Lubok Antu, Sri Aman, Roban, Debak, Kabong, Lingga, Engkelili, Betong, Spaoh, Pusa, Saratok", Coordinate{1.2376, 111.4621}},
+		{"SWK07", "Serian, Simunjan, Samarahan, Sebuyau, Meludam", Coordinate{1.4590, 110.4883}},
+		{"SWK08", "Kuching, Bau, Lundu, Sematan", Coordinate{1.5535, 110.3593}},
+		{"SWK09", "Zon Khas (Kampung Patarikan)", Coordinate{4.9000, 115.4500}},
+	}},
+	{"TRG", "Terengganu", []bundledZone{
+		{"TRG01", "Kuala Terengganu, Marang, Kuala Nerus", Coordinate{5.3302, 103.1408}},
+		{"TRG02", "Besut, Setiu", Coordinate{5.7333, 102.4914}},
+		{"TRG03", "Hulu Terengganu", Coordinate{5.0736, 103.0087}},
+		{"TRG04", "Dungun, Kemaman", Coordinate{4.7566, 103.4160}},
+	}},
+	{"WLY", "Wilayah Persekutuan", []bundledZone{
+		{"WLY01", "Kuala Lumpur, Putrajaya", Coordinate{3.1390, 101.6869}},
+		{"WLY02", "Labuan", Coordinate{5.2831, 115.2308}},
+	}},
+}
+
+// ZoneCoordinate returns the reference point of a zone
+func ZoneCoordinate(zoneId string) (Coordinate, bool) {
+	for _, s := range bundledStates {
+		for _, z := range s.Zones {
+			if z.ID == zoneId {
+				return z.Coordinate, true
+			}
+		}
+	}
+	return Coordinate{}, false
+}
@@ -2,7 +2,6 @@ package services
 
 import (
 	"fmt"
-	"github.com/gocolly/colly"
 	"github.com/sayuthisobri/waktu-solat/common"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -35,6 +34,55 @@ func (zs *ZoneStates) ToAlfredResponse() common.AlfredResponse {
 	return common.AlfredResponse{Items: items}
 }
 
+type ZoneResponse struct {
+	ID        string `json:"id"`
+	Locations string `json:"locations"`
+	State     string `json:"state,omitempty"`
+}
+
+type StateResponse struct {
+	ID    string         `json:"id"`
+	Name  string         `json:"name"`
+	Zones []ZoneResponse `json:"zones"`
+}
+
+func (zs *ZoneStates) ToJSONResponse() []StateResponse {
+	res := []StateResponse{}
+	for _, s := range *zs {
+		state := StateResponse{ID: s.ID, Name: s.Name, Zones: []ZoneResponse{}}
+		for _, z := range s.Zones {
+			state.Zones = append(state.Zones, ZoneResponse{ID: z.ID, Locations: z.Locations, State: s.Name})
+		}
+		res = append(res, state)
+	}
+	return res
+}
+
+func (ms ZoneMatches) ToAlfredResponse() common.AlfredResponse {
+	var states []State
+	for _, m := range ms {
+		states = append(states, State{Name: m.State, Zones: []Zone{m.Zone}})
+	}
+	zs := ZoneStates(states)
+	return zs.ToAlfredResponse()
+}
+
+func (ms ZoneMatches) ToJSONResponse() []ZoneResponse {
+	res := []ZoneResponse{}
+	for _, m := range ms {
+		res = append(res, ZoneResponse{ID: m.Zone.ID, Locations: m.Zone.Locations, State: m.State})
+	}
+	return res
+}
+
+func (z *Zone) ToJSONResponse() ZoneResponse {
+	res := ZoneResponse{ID: z.ID, Locations: z.Locations}
+	if z.State != nil {
+		res.State = z.State.Name
+	}
+	return res
+}
+
 type State struct {
 	ID        string
 	CreatedAt time.Time
@@ -55,9 +103,13 @@ type Zone struct {
 }
 
 func GetZoneById(ctx *common.Ctx, id string) *Zone {
-	db, _ := OpenDb(ctx)
+	db, err := OpenDb(ctx)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
 
-	zone := &Zone{ID: id}
+	zone := &Zone{ID: strings.ToUpper(id)}
 	if db.First(&zone).Error == nil {
 		return zone
 	}
@@ -67,49 +119,90 @@ func GetZoneById(ctx *common.Ctx, id string) *Zone {
 
 func GetZoneStates(ctx *common.Ctx) []State {
 	var states []State
-	db, _ := OpenDb(ctx)
-	if db != nil {
+	if db, err := OpenDb(ctx); err == nil {
 		_ = db.Model(&State{}).Preload("Zones").Find(&states)
+	} else {
+		log.Println(err)
 	}
 	if len(states) == 0 {
-		states = fetchZones(ctx)
+		var err error
+		if states, err = fetchZones(ctx); err != nil {
+			log.Println(err)
+			// Read from the bundled zone list until the zones can be fetched
+			states, _ = (&CalculationProvider{}).Zones()
+		}
 	}
 	return states
 }
 
-func fetchZones(ctx *common.Ctx) []State {
-	var states []State
-	c := colly.NewCollector()
+// ZoneChanges lists the zones added, removed or renamed by UpdateZones
+type ZoneChanges struct {
+	Added   []Zone
+	Removed []Zone
+	Renamed []Zone
+}
 
-	c.OnHTML("select#inputZone:first-child", func(p *colly.HTMLElement) {
-		db, _ := OpenDb(ctx)
-		p.ForEach("optgroup", func(_ int, eState *colly.HTMLElement) {
-			state := &State{
-				Name: eState.Attr("label"),
+// UpdateZones re-fetches the zone list and reports what changed since the last fetch
+func UpdateZones(ctx *common.Ctx) (*ZoneChanges, error) {
+	db, err := OpenDb(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var existing []Zone
+	db.Find(&existing)
+	states, err := fetchZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]Zone, len(existing))
+	for _, z := range existing {
+		known[z.ID] = z
+	}
+	changes := &ZoneChanges{}
+	for _, s := range states {
+		for _, z := range s.Zones {
+			if old, ok := known[z.ID]; !ok {
+				changes.Added = append(changes.Added, z)
+			} else if old.Locations != z.Locations {
+				changes.Renamed = append(changes.Renamed, z)
 			}
-			eState.ForEach("option", func(i int, eZone *colly.HTMLElement) {
-				id := eZone.Attr("value")
-				if i == 0 {
-					state.ID = id[:3]
-				}
-				zone := &Zone{
-					ID:        id,
-					Locations: processLocationName(eZone.Text),
-					State:     state,
-				}
-				state.Zones = append(state.Zones, *zone)
-				//zones = append(zones, *zone)
-			})
-			states = append(states, *state)
-		})
-		updateRecords(&states, db)
-	})
-	_ = c.Visit(`https://www.e-solat.gov.my/index.php?siteId=24&pageId=24`)
-	return states
+			delete(known, z.ID)
+		}
+	}
+	for _, z := range known {
+		changes.Removed = append(changes.Removed, z)
+		db.Delete(&z)
+	}
+	return changes, nil
+}
+
+func fetchZones(ctx *common.Ctx) ([]State, error) {
+	provider, err := GetProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+	states, err := provider.Zones()
+	recordFetch("zones", provider.Name(), err)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch zones from %s: %w", provider.Name(), err)
+	}
+	if len(states) == 0 {
+		return nil, fmt.Errorf("unable to fetch zones from %s: empty zone list", provider.Name())
+	}
+	db, err := OpenDb(ctx)
+	if err != nil {
+		return nil, err
+	}
+	updateRecords(&states, db)
+	return states, nil
 }
 
 func getZone(ctx *common.Ctx, zoneId string) *Zone {
-	db, _ := OpenDb(ctx)
+	db, err := OpenDb(ctx)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
 	zone := &Zone{ID: zoneId}
 	tx := db.First(zone)
 	if tx.Error != nil {
@@ -141,7 +234,3 @@ func updateRecords(states *[]State, db *gorm.DB) {
 		}
 	}
 }
-
-func processLocationName(locations string) string {
-	return strings.ReplaceAll(locations[8:], " dan ", ", ")
-}